@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// maxBatch is the most reveals a commit may have, keeping the commit and its
+// reveals within the 25 transaction descendant limit of the mempool. That only
+// holds with no unconfirmed ancestor, so batches fund from confirmed coins.
+const maxBatch = 24
+
+// inscriptionContent is the content of an inscription to reveal.
+type inscriptionContent struct {
+	contentType string
+	body        []byte
+}
+
+// inscribeBatch inscribes contents to the address to with one commit paying an
+// output per inscription, funded by from, and a reveal spending each. The
+// reveals only depend on the commit and are broadcast in the order of
+// contents, each recorded in journal before anything is broadcast.
+func inscribeBatch(from string, wif *btcutil.WIF, to string, contents []*inscriptionContent, postage int64, feerate int64, source *coinSource, journal *journal, backend ChainBackend, net *chaincfg.Params) ([]string, error) {
+	if len(contents) == 0 || len(contents) > maxBatch {
+		return nil, fmt.Errorf("batch of %d inscriptions out of range [1, %d]", len(contents), maxBatch)
+	}
+	if err := checkPostage(to, postage, net); err != nil {
+		return nil, err
+	}
+	if len(contents) > 1 {
+		confirmed := *source
+		confirmed.confirmedOnly = true
+		source = &confirmed
+	}
+	commitIndexes := make([]uint32, len(contents))
+	commitKeys := make([]*btcec.PrivateKey, len(contents))
+	scripts := make([][]byte, len(contents))
+	outputs := make([]*wire.TxOut, len(contents))
+	for i, content := range contents {
+		commitIndexes[i] = journal.nextCommitIndex()
+		key, err := commitKey(wif, commitIndexes[i], net)
+		if err != nil {
+			return nil, err
+		}
+		script := inscriptionScript(key.PubKey(), content.contentType, content.body)
+		if err := checkRevealWeight(script); err != nil {
+			return nil, err
+		}
+		journal.commitLeaf(commitIndexes[i], script)
+		commitAddress, err := inscriptionAddress(key.PubKey(), script, net)
+		if err != nil {
+			return nil, err
+		}
+		decodedCommitAddr, err := decodeAddress(commitAddress, net)
+		if err != nil {
+			return nil, err
+		}
+		commitAddrByte, err := txscript.PayToAddrScript(decodedCommitAddr)
+		if err != nil {
+			return nil, err
+		}
+		revealFee, err := estimateRevealFee(script, to, feerate, net)
+		if err != nil {
+			return nil, err
+		}
+		// the reveal pays the postage and its fee, with no change
+		commitKeys[i], scripts[i] = key, script
+		outputs[i] = wire.NewTxOut(postage+revealFee, commitAddrByte)
+	}
+	commitTx, err := sendOutputs(from, wif, outputs, feerate, source, backend, net)
+	if err != nil && source.confirmedOnly {
+		return nil, fmt.Errorf("batches fund from confirmed coins only: %w", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	commitRaw, err := txHex(commitTx)
+	if err != nil {
+		return nil, err
+	}
+	commitTxId := commitTx.TxHash().String()
+
+	ids := make([]string, len(contents))
+	entries := make([]*journalEntry, len(contents))
+	for i := range contents {
+		reveal, err := revealTx(commitTx, uint32(i), commitKeys[i], scripts[i], to, postage, net)
+		if err != nil {
+			return nil, err
+		}
+		revealRaw, err := txHex(reveal)
+		if err != nil {
+			return nil, err
+		}
+		commitWif, err := btcutil.NewWIF(commitKeys[i], net, true)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = fmt.Sprintf("%s:%d", commitTxId, i)
+		entries[i] = &journalEntry{
+			CommitKey:   commitWif.String(),
+			CommitIndex: commitIndexes[i],
+			CommitVout:  uint32(i),
+			Script:      hex.EncodeToString(scripts[i]),
+			CommitTx:    commitRaw,
+			RevealTx:    revealRaw,
+			From:        from,
+			To:          to,
+		}
+		if err := journal.record(ids[i], entries[i], journalPrepared); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := postTransaction(backend, commitTx); err != nil {
+		return nil, err
+	}
+	fmt.Println("commit: ", commitTxId)
+	for i := range contents {
+		if err := journal.record(ids[i], entries[i], journalCommitted); err != nil {
+			return nil, err
+		}
+	}
+
+	inscriptionIds := make([]string, 0, len(contents))
+	for i := range contents {
+		revealTxId, err := backend.PostTransaction(entries[i].RevealTx)
+		if err != nil {
+			return inscriptionIds, fmt.Errorf("reveal of commit %s: %w, run resume to retry or sweep it", ids[i], err)
+		}
+		if err := journal.record(ids[i], entries[i], journalRevealed); err != nil {
+			return inscriptionIds, err
+		}
+		fmt.Println("reveal: ", revealTxId)
+		inscriptionIds = append(inscriptionIds, fmt.Sprintf("%si%d", revealTxId, 0))
+	}
+	return inscriptionIds, nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+func Test_InscribeBatch(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, from, 80000)
+	source := &coinSource{strategy: coinselect.Select}
+	j := newTestJournal(t)
+
+	inscriptionIds, err := inscribeTransfer(from, wifs[0], from, "ordi", "10", 3, 546, 2, source, j, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inscriptionIds) != 3 || len(backend.posted) != 4 {
+		t.Fatalf("%d inscriptions in %d transactions, expected 3 in 4", len(inscriptionIds), len(backend.posted))
+	}
+	commit := backend.posted[0]
+	verifyTx(t, backend, commit)
+	checkFeeRate(t, backend, commit, 2)
+	for i, reveal := range backend.posted[1:] {
+		if reveal.TxIn[0].PreviousOutPoint.Hash != commit.TxHash() || reveal.TxIn[0].PreviousOutPoint.Index != uint32(i) {
+			t.Fatalf("reveal %d spends %v", i, reveal.TxIn[0].PreviousOutPoint)
+		}
+		if inscriptionIds[i] != fmt.Sprintf("%si0", reveal.TxHash()) {
+			t.Fatalf("reveal %d out of order", i)
+		}
+		if len(reveal.TxOut) != 1 || reveal.TxOut[0].Value != 546 {
+			t.Fatalf("reveal %d pays %d outputs, expected only the postage", i, len(reveal.TxOut))
+		}
+		verifyTx(t, backend, reveal)
+		checkFeeRate(t, backend, reveal, 2)
+	}
+	if len(j.pending()) != 0 || len(j.Entries) != 3 {
+		t.Fatalf("journal has %d entries, %d pending", len(j.Entries), len(j.pending()))
+	}
+
+	if _, err := inscribeTransfer(from, wifs[0], from, "ordi", "10", maxBatch+1, 546, 2, source, j, backend, net); err == nil {
+		t.Fatal("batch past the mempool descendant limit accepted")
+	}
+
+	// unconfirmed change would add an ancestor past the descendant limit
+	backend.unconfirmed = true
+	if _, err := inscribeTransfer(from, wifs[0], from, "ordi", "10", 1, 546, 2, source, j, backend, net); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := inscribeTransfer(from, wifs[0], from, "ordi", "10", 2, 546, 2, source, j, backend, net); err == nil {
+		t.Fatal("batch funded from unconfirmed change")
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/btcsuite/btcd/btcutil"
+)
+
+// bitcoindBackend is a ChainBackend on a bitcoind JSON-RPC server. Address
+// lookups use scantxoutset so no wallet is needed, transaction lookups need
+// txindex=1 for transactions that are not in the mempool. scantxoutset only
+// sees confirmed coins, so unconfirmed ones are followed from there through
+// gettxspendingprevout (bitcoind 24 and later): the change of a transaction
+// spending the coins of an address is seen, an unconfirmed payment to it from
+// elsewhere is not.
+type bitcoindBackend struct {
+	url      string
+	user     string
+	password string
+	client   *http.Client
+}
+
+func newBitcoindBackend(url string, user string, password string) *bitcoindBackend {
+	return &bitcoindBackend{
+		url:      url,
+		user:     user,
+		password: password,
+		client:   &http.Client{},
+	}
+}
+
+type bitcoindRequest struct {
+	JsonRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type bitcoindResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *bitcoindBackend) call(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	payload, err := json.Marshal(&bitcoindRequest{JsonRPC: "1.0", ID: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", b.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if b.user != "" {
+		req.SetBasicAuth(b.user, b.password)
+	}
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	response := &bitcoindResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("%s: %s: %s", method, res.Status, body)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s: %s (%d)", method, response.Error.Message, response.Error.Code)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+type scanTxOutSetResult struct {
+	Height   int `json:"height"`
+	Unspents []struct {
+		Txid         string  `json:"txid"`
+		Vout         int     `json:"vout"`
+		Amount       float64 `json:"amount"`
+		Height       int     `json:"height"`
+		ScriptPubKey string  `json:"scriptPubKey"`
+	} `json:"unspents"`
+	TotalAmount float64 `json:"total_amount"`
+}
+
+func (b *bitcoindBackend) scanAddress(address string) (*scanTxOutSetResult, error) {
+	result := &scanTxOutSetResult{}
+	descriptor := fmt.Sprintf("addr(%s)", address)
+	err := b.call("scantxoutset", result, "start", []string{descriptor})
+	return result, err
+}
+
+func (b *bitcoindBackend) GetBalance(address string) (int64, error) {
+	result, err := b.scanAddress(address)
+	if err != nil {
+		return 0, err
+	}
+	amount, err := btcutil.NewAmount(result.TotalAmount)
+	if err != nil {
+		return 0, err
+	}
+	return int64(amount), nil
+}
+
+func (b *bitcoindBackend) GetUnspentUtxo(address string) ([]*unspentUtxo, error) {
+	result, err := b.scanAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Unspents) == 0 {
+		return []*unspentUtxo{}, nil
+	}
+	utxos := make([]*unspentUtxo, 0, len(result.Unspents))
+	for _, unspent := range result.Unspents {
+		amount, err := btcutil.NewAmount(unspent.Amount)
+		if err != nil {
+			return nil, err
+		}
+		utxo := &unspentUtxo{Txid: unspent.Txid, Vout: unspent.Vout, Value: int(amount)}
+		utxo.Status.Confirmed = true
+		utxo.Status.BlockHeight = unspent.Height
+		utxos = append(utxos, utxo)
+	}
+	pkScript, err := hex.DecodeString(result.Unspents[0].ScriptPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return b.followMempool(utxos, pkScript)
+}
+
+type txSpendingPrevOut struct {
+	Txid         string `json:"txid"`
+	Vout         int    `json:"vout"`
+	SpendingTxid string `json:"spendingtxid"`
+}
+
+// followMempool replaces each of utxos spent by a mempool transaction with the
+// outputs of that transaction paying pkScript, until none is spent.
+func (b *bitcoindBackend) followMempool(utxos []*unspentUtxo, pkScript []byte) ([]*unspentUtxo, error) {
+	unspent := make([]*unspentUtxo, 0, len(utxos))
+	for len(utxos) > 0 {
+		prevouts := make([]map[string]interface{}, 0, len(utxos))
+		for _, utxo := range utxos {
+			prevouts = append(prevouts, map[string]interface{}{"txid": utxo.Txid, "vout": utxo.Vout})
+		}
+		var spends []*txSpendingPrevOut
+		if err := b.call("gettxspendingprevout", &spends, prevouts); err != nil {
+			return nil, err
+		}
+		spentBy := make(map[string]string, len(spends))
+		for _, spend := range spends {
+			spentBy[fmt.Sprintf("%s:%d", spend.Txid, spend.Vout)] = spend.SpendingTxid
+		}
+		followed := make(map[string]bool)
+		next := make([]*unspentUtxo, 0)
+		for _, utxo := range utxos {
+			spending := spentBy[utxoOutpoint(utxo)]
+			if spending == "" {
+				unspent = append(unspent, utxo)
+				continue
+			}
+			if followed[spending] {
+				continue
+			}
+			followed[spending] = true
+			raw, err := b.GetRawTransaction(spending)
+			if err != nil {
+				return nil, err
+			}
+			tx, err := decodeTxHex(raw)
+			if err != nil {
+				return nil, err
+			}
+			for vout, txOut := range tx.TxOut {
+				if bytes.Equal(txOut.PkScript, pkScript) {
+					next = append(next, &unspentUtxo{Txid: spending, Vout: vout, Value: int(txOut.Value)})
+				}
+			}
+		}
+		utxos = next
+	}
+	return unspent, nil
+}
+
+func (b *bitcoindBackend) GetRawTransaction(txid string) (string, error) {
+	var raw string
+	err := b.call("getrawtransaction", &raw, txid)
+	return raw, err
+}
+
+func (b *bitcoindBackend) PostTransaction(raw string) (string, error) {
+	var txid string
+	err := b.call("sendrawtransaction", &txid, raw)
+	return txid, err
+}
+
+func (b *bitcoindBackend) GetBlockHeight() (int64, error) {
+	var height int64
+	err := b.call("getblockcount", &height)
+	return height, err
+}
+
+func (b *bitcoindBackend) GetRawBlock(height int64) ([]byte, error) {
+	var hash string
+	if err := b.call("getblockhash", &hash, height); err != nil {
+		return nil, err
+	}
+	var raw string
+	if err := b.call("getblock", &raw, hash, 0); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(raw)
+}
+
+func (b *bitcoindBackend) EstimateFeeRate(target int) (float64, error) {
+	result := &struct {
+		FeeRate float64  `json:"feerate"`
+		Errors  []string `json:"errors"`
+	}{}
+	if err := b.call("estimatesmartfee", result, target); err != nil {
+		return 0, err
+	}
+	if result.FeeRate <= 0 {
+		return 0, fmt.Errorf("estimatesmartfee: %v", result.Errors)
+	}
+	// BTC/kvB to sat/vB
+	return result.FeeRate * btcutil.SatoshiPerBitcoin / 1000, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Test_BitcoindMempoolUtxos sees the change of a mempool transaction spending
+// the only confirmed coin scantxoutset returns, and not the spent coin.
+func Test_BitcoindMempoolUtxos(t *testing.T) {
+	pkScript := append([]byte{0x00, 0x14}, bytes.Repeat([]byte{1}, 20)...)
+	confirmed := chainhash.Hash{2}
+	spending := wire.NewMsgTx(2)
+	spending.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&confirmed, 0), nil, nil))
+	spending.AddTxOut(wire.NewTxOut(600, append([]byte{0x00, 0x14}, bytes.Repeat([]byte{3}, 20)...)))
+	spending.AddTxOut(wire.NewTxOut(1400, pkScript))
+	raw, err := txHex(spending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := spending.TxHash().String()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := &struct {
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+			t.Error(err)
+			return
+		}
+		result := ""
+		switch request.Method {
+		case "scantxoutset":
+			result = fmt.Sprintf(`{"height":100,"unspents":[{"txid":"%s","vout":0,"amount":0.00002000,"height":90,"scriptPubKey":"%x"}],"total_amount":0.00002000}`, confirmed, pkScript)
+		case "gettxspendingprevout":
+			prevouts := []*txSpendingPrevOut{}
+			if err := json.Unmarshal(request.Params[0], &prevouts); err != nil {
+				t.Error(err)
+				return
+			}
+			for _, prevout := range prevouts {
+				if prevout.Txid == confirmed.String() {
+					prevout.SpendingTxid = change
+				}
+			}
+			data, _ := json.Marshal(prevouts)
+			result = string(data)
+		case "getrawtransaction":
+			result = `"` + raw + `"`
+		default:
+			result = "null"
+		}
+		fmt.Fprintf(w, `{"result":%s,"error":null}`, result)
+	}))
+	defer server.Close()
+
+	utxos, err := newBitcoindBackend(server.URL, "", "").GetUnspentUtxo("bc1q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 1 || utxos[0].Txid != change || utxos[0].Vout != 1 || utxos[0].Value != 1400 || utxos[0].Status.Confirmed {
+		t.Fatalf("utxos %+v, expected only the unconfirmed change", utxos)
+	}
+}
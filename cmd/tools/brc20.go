@@ -1,93 +1,151 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
 	"fmt"
-	"strconv"
+	"strings"
+
+	"brc20tools/txsize"
 
-	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/okx/go-wallet-sdk/coins/bitcoin/brc20"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 )
 
-func brc20Mint(from string, wif *btcutil.WIF, to string, ticker string, amount string, feerate int64, net *chaincfg.Params) (string, error) {
-	commitPrivkey, _ := btcec.NewPrivateKey()
-	//test
-	commitWfi, _ := btcutil.NewWIF(commitPrivkey, net, true)
-	fmt.Println(commitWfi.String())
-	//end test
-	contentType := "text/plain;charset=utf-8"
-	body := []byte(fmt.Sprintf(`{"p":"brc-20","op":"%s","tick":"%s","amt":"%s"}`, "mint", ticker, amount))
-	return inscribe(from, wif, to, contentType, body, feerate, net)
+const maxDecimals = 18
+
+// checkTick checks ticker against the brc20 rules, 4 bytes or 5 bytes for self-mint tickers.
+func checkTick(ticker string) error {
+	if len(ticker) != 4 && len(ticker) != 5 {
+		return fmt.Errorf("tick %q must be 4 or 5 bytes", ticker)
+	}
+	if strings.ContainsAny(ticker, `"\`) {
+		return fmt.Errorf("tick %q contains invalid characters", ticker)
+	}
+	return nil
+}
+
+// checkAmount checks amount is a positive decimal with at most decimals fractional digits.
+func checkAmount(amount string, decimals int64) error {
+	if decimals < 0 || decimals > maxDecimals {
+		return fmt.Errorf("decimals %d out of range [0, %d]", decimals, maxDecimals)
+	}
+	integer, fraction, hasPoint := strings.Cut(amount, ".")
+	if integer == "" || (hasPoint && fraction == "") {
+		return fmt.Errorf("invalid amount: %q", amount)
+	}
+	positive := false
+	for _, c := range integer + fraction {
+		if c < '0' || c > '9' {
+			return fmt.Errorf("invalid amount: %q", amount)
+		}
+		if c != '0' {
+			positive = true
+		}
+	}
+	if int64(len(fraction)) > decimals {
+		return fmt.Errorf("amount %s exceeds %d decimals", amount, decimals)
+	}
+	if !positive {
+		return fmt.Errorf("amount must be positive: %s", amount)
+	}
+	return nil
 }
 
-func inscribeTransfer(from string, wif *btcutil.WIF, to string, ticker string, amount string, feerate int64, net *chaincfg.Params) (string, error) {
-	commitPrivkey, _ := btcec.NewPrivateKey()
-	//test
-	commitWfi, _ := btcutil.NewWIF(commitPrivkey, net, true)
-	fmt.Println(commitWfi.String())
-	//end test
+// brc20Contents returns count inscriptions of the brc20 op of amount of ticker.
+func brc20Contents(op string, ticker string, amount string, count int) []*inscriptionContent {
 	contentType := "text/plain;charset=utf-8"
-	body := []byte(fmt.Sprintf(`{"p":"brc-20","op":"%s","tick":"%s","amt":"%s"}`, "transfer", ticker, amount))
-	return inscribe(from, wif, to, contentType, body, feerate, net)
+	body := []byte(fmt.Sprintf(`{"p":"brc-20","op":"%s","tick":"%s","amt":"%s"}`, op, ticker, amount))
+	contents := make([]*inscriptionContent, count)
+	for i := range contents {
+		contents[i] = &inscriptionContent{contentType, body}
+	}
+	return contents
 }
 
-func inscribe(from string, wif *btcutil.WIF, to string, contentType string, body []byte, feerate int64, net *chaincfg.Params) (string, error) {
-	commitPrivkey, _ := btcec.NewPrivateKey()
-	script, err := brc20.CreateInscriptionScript(
-		commitPrivkey,
-		contentType,
-		body)
-	if err != nil {
-		return "", err
+// brc20Mint inscribes count mints of amount of ticker with one commit.
+func brc20Mint(from string, wif *btcutil.WIF, to string, ticker string, amount string, count int, postage int64, feerate int64, source *coinSource, journal *journal, backend ChainBackend, net *chaincfg.Params) ([]string, error) {
+	return inscribeBatch(from, wif, to, brc20Contents("mint", ticker, amount, count), postage, feerate, source, journal, backend, net)
+}
+
+// inscribeTransfer inscribes count transfers of amount of ticker with one commit.
+func inscribeTransfer(from string, wif *btcutil.WIF, to string, ticker string, amount string, count int, postage int64, feerate int64, source *coinSource, journal *journal, backend ChainBackend, net *chaincfg.Params) ([]string, error) {
+	return inscribeBatch(from, wif, to, brc20Contents("transfer", ticker, amount, count), postage, feerate, source, journal, backend, net)
+}
+
+// deployBody returns the deploy inscription of ticker with supply max, mint limit
+// lim and decimals. A 5 byte ticker is deployed for self-mint, only its deploy
+// inscription's parent may mint it, and a max of 0 leaves its supply unlimited.
+func deployBody(ticker string, max string, lim string, decimals int64) ([]byte, error) {
+	if err := checkTick(ticker); err != nil {
+		return nil, err
+	}
+	selfMint := len(ticker) == 5
+	if !selfMint || max != "0" {
+		if err := checkAmount(max, decimals); err != nil {
+			return nil, fmt.Errorf("max: %w", err)
+		}
 	}
-	commitAddress, err := brc20.NewTapRootAddressWithScript(commitPrivkey, script, net)
+	if err := checkAmount(lim, decimals); err != nil {
+		return nil, fmt.Errorf("lim: %w", err)
+	}
+	body := fmt.Sprintf(`{"p":"brc-20","op":"%s","tick":"%s","max":"%s","lim":"%s","dec":"%d"`, "deploy", ticker, max, lim, decimals)
+	if selfMint {
+		body += `,"self_mint":"true"`
+	}
+	return []byte(body + "}"), nil
+}
+
+func brc20Deploy(from string, wif *btcutil.WIF, to string, ticker string, max string, lim string, decimals int64, postage int64, feerate int64, source *coinSource, journal *journal, backend ChainBackend, net *chaincfg.Params) (string, error) {
+	body, err := deployBody(ticker, max, lim, decimals)
 	if err != nil {
 		return "", err
 	}
-	// fmt.Println(to)
-	const commitValue = int64(2000)
-	commitTx, err := sendSatoshi(from, wif, commitAddress, commitValue, feerate, net)
+	contentType := "text/plain;charset=utf-8"
+	return inscribe(from, wif, to, contentType, body, postage, feerate, source, journal, backend, net)
+}
+
+// inscribe commits to and reveals an inscription of body to the address to, funded
+// by from. Both transactions are recorded in journal before either is broadcast,
+// so a failed reveal can be finished or swept back with resume.
+func inscribe(from string, wif *btcutil.WIF, to string, contentType string, body []byte, postage int64, feerate int64, source *coinSource, journal *journal, backend ChainBackend, net *chaincfg.Params) (string, error) {
+	inscriptionIds, err := inscribeBatch(from, wif, to, []*inscriptionContent{{contentType, body}}, postage, feerate, source, journal, backend, net)
 	if err != nil {
 		return "", err
 	}
+	return inscriptionIds[0], nil
+}
 
-	//reveal
-	inscription := brc20.NewInscription(contentType, body)
-	builder := brc20.NewTxBuildV1(net)
-	builder.AddInput(commitTx.TxHash().String(),
-		0,
-		hex.EncodeToString(commitPrivkey.Serialize()),
-		commitAddress,
-		strconv.Itoa(int(commitValue)),
-		inscription,
-	)
-	const minValue = int64(546)
-	builder.AddOutput(to, strconv.Itoa(int(minValue)))
-	const TX_SIZE = int64(340)
-	builder.AddOutput(from, strconv.Itoa(int(commitValue-TX_SIZE*feerate-minValue)))
-	revealRaw, err := builder.Build()
+// estimateRevealFee is the fee of a reveal spending the inscription script by its
+// only tap leaf to an inscription output to, without change.
+func estimateRevealFee(script []byte, to string, feerate int64, net *chaincfg.Params) (int64, error) {
+	size := &txsize.Estimator{}
+	size.AddTaprootScriptInput([]int{txsize.SchnorrSigLen}, len(script), 0)
+	addr, err := decodeAddress(to, net)
 	if err != nil {
-		return "", nil
+		return 0, err
 	}
-	//print
-	fmt.Println("privkey: ", hex.EncodeToString(commitPrivkey.Serialize()))
-	var commitTxBuffer bytes.Buffer
-	err = commitTx.Serialize(&commitTxBuffer)
+	pkScript, err := txscript.PayToAddrScript(addr)
 	if err != nil {
-		return "", nil
+		return 0, err
 	}
-	commitTxId, err := postTransaction(hex.EncodeToString(commitTxBuffer.Bytes()))
+	size.AddOutput(pkScript)
+	return size.Fee(feerate), nil
+}
+
+// checkPostage refuses a postage the inscription output to address could not relay with.
+func checkPostage(address string, postage int64, net *chaincfg.Params) error {
+	addr, err := decodeAddress(address, net)
 	if err != nil {
-		return "", nil
+		return err
 	}
-	fmt.Println("commit: ", commitTxId)
-	revealTxId, err := postTransaction(revealRaw)
+	pkScript, err := txscript.PayToAddrScript(addr)
 	if err != nil {
-		return "", nil
+		return err
+	}
+	if mempool.IsDust(wire.NewTxOut(postage, pkScript), mempool.DefaultMinRelayTxFee) {
+		return fmt.Errorf("postage %d is dust for %s", postage, address)
 	}
-	fmt.Println("reveal: ", revealTxId)
-	return fmt.Sprintf("%si%d", revealTxId, 0), nil
+	return nil
 }
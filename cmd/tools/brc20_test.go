@@ -0,0 +1,84 @@
+package main
+
+import "testing"
+
+func Test_CheckTick(t *testing.T) {
+	for _, tick := range []string{"qwpo", "ordi", "sats1", "😀"} {
+		if err := checkTick(tick); err != nil {
+			t.Errorf("checkTick(%q): %v", tick, err)
+		}
+	}
+	for _, tick := range []string{"", "abc", "abcdef", `ab"c`} {
+		if err := checkTick(tick); err == nil {
+			t.Errorf("checkTick(%q) should fail", tick)
+		}
+	}
+}
+
+func Test_CheckAmount(t *testing.T) {
+	valid := []struct {
+		amount   string
+		decimals int64
+	}{
+		{"1000", 18},
+		{"0.5", 1},
+		{"1.000000000000000001", 18},
+		{"01", 0},
+	}
+	for _, c := range valid {
+		if err := checkAmount(c.amount, c.decimals); err != nil {
+			t.Errorf("checkAmount(%q, %d): %v", c.amount, c.decimals, err)
+		}
+	}
+	invalid := []struct {
+		amount   string
+		decimals int64
+	}{
+		{"", 18},
+		{"0", 18},
+		{"0.000", 18},
+		{"-1", 18},
+		{"1.", 18},
+		{".5", 18},
+		{"1e3", 18},
+		{"0.55", 1},
+		{"1", 19},
+	}
+	for _, c := range invalid {
+		if err := checkAmount(c.amount, c.decimals); err == nil {
+			t.Errorf("checkAmount(%q, %d) should fail", c.amount, c.decimals)
+		}
+	}
+}
+
+func Test_DeployBody(t *testing.T) {
+	body, err := deployBody("ordi", "21000000", "1000", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"p":"brc-20","op":"deploy","tick":"ordi","max":"21000000","lim":"1000","dec":"18"}`; string(body) != expected {
+		t.Errorf("deploy body %s, expected %s", body, expected)
+	}
+	body, err = deployBody("sats1", "0", "1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"p":"brc-20","op":"deploy","tick":"sats1","max":"0","lim":"1","dec":"0","self_mint":"true"}`; string(body) != expected {
+		t.Errorf("self-mint deploy body %s, expected %s", body, expected)
+	}
+	invalid := []struct {
+		tick, max, lim string
+		decimals       int64
+	}{
+		{"abc", "1000", "1", 18},
+		{"ordi", "0", "1", 18},
+		{"ordi", "1000", "0", 18},
+		{"ordi", "1000.5", "1", 0},
+		{"ordi", "1000", "1", 19},
+	}
+	for _, c := range invalid {
+		if _, err := deployBody(c.tick, c.max, c.lim, c.decimals); err == nil {
+			t.Errorf("deployBody(%q, %q, %q, %d) should fail", c.tick, c.max, c.lim, c.decimals)
+		}
+	}
+}
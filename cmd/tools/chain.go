@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// ChainBackend is where the tools read chain state from and broadcast transactions to.
+type ChainBackend interface {
+	// GetBalance returns the confirmed balance of address in satoshi.
+	GetBalance(address string) (int64, error)
+	GetUnspentUtxo(address string) ([]*unspentUtxo, error)
+	GetRawTransaction(txid string) (string, error)
+	// PostTransaction broadcasts a hex encoded transaction and returns its txid.
+	PostTransaction(raw string) (string, error)
+	// GetBlockHeight returns the height of the best block.
+	GetBlockHeight() (int64, error)
+	// GetRawBlock returns the serialized block at height of the best chain.
+	GetRawBlock(height int64) ([]byte, error)
+	// EstimateFeeRate returns the fee rate in sat/vB expected to confirm within target blocks.
+	EstimateFeeRate(target int) (float64, error)
+}
+
+type unspentUtxo struct {
+	Txid   string `json:"txid"`
+	Vout   int    `json:"vout"`
+	Status struct {
+		Confirmed   bool   `json:"confirmed"`
+		BlockHeight int    `json:"block_height"`
+		BlockHash   string `json:"block_hash"`
+		BlockTime   int    `json:"block_time"`
+	} `json:"status"`
+	Value int `json:"value"`
+}
+
+// getChainBackend builds the backend chosen by --backend for net.
+func getChainBackend(cmd *cli.Command, net *chaincfg.Params) (ChainBackend, error) {
+	backend, err := newChainBackend(cmd, net)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Bool("dry-run") {
+		// without a usable indexer the summary traces inscriptions on its own
+		indexer, err := getIndexer(cmd, net)
+		if err != nil {
+			indexer = nil
+		}
+		return newDryRunBackend(backend, indexer, os.Stdout, net), nil
+	}
+	return backend, nil
+}
+
+func newChainBackend(cmd *cli.Command, net *chaincfg.Params) (ChainBackend, error) {
+	switch name := strings.ToLower(cmd.String("backend")); name {
+	case "esplora", "mempool":
+		api, err := mempoolAPI(net)
+		if err != nil {
+			return nil, err
+		}
+		return newEsploraBackend(api), nil
+	case "bitcoind":
+		url := os.Getenv("BITCOIND_RPC_URL")
+		if url == "" {
+			return nil, fmt.Errorf("bitcoind backend needs BITCOIND_RPC_URL")
+		}
+		return newBitcoindBackend(url, os.Getenv("BITCOIND_RPC_USER"), os.Getenv("BITCOIND_RPC_PASS")), nil
+	case "electrum":
+		server := os.Getenv("ELECTRUM_SERVER")
+		if server == "" {
+			return nil, fmt.Errorf("electrum backend needs ELECTRUM_SERVER")
+		}
+		return newElectrumBackend(server, os.Getenv("ELECTRUM_TLS") == "true", net), nil
+	default:
+		return nil, fmt.Errorf("unknown backend: %s", name)
+	}
+}
+
+func getTransction(backend ChainBackend, txid string) (*wire.MsgTx, error) {
+	raw, err := backend.GetRawTransaction(txid)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	data, err := hex.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Deserialize(bytes.NewReader(data))
+	return tx, err
+}
+
+// prevOutFetcher returns the outputs spent by tx, fetched from backend.
+func prevOutFetcher(tx *wire.MsgTx, backend ChainBackend) (*txscript.MultiPrevOutFetcher, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for _, txIn := range tx.TxIn {
+		preInput, err := getTransction(backend, txIn.PreviousOutPoint.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		if int(txIn.PreviousOutPoint.Index) >= len(preInput.TxOut) {
+			return nil, fmt.Errorf("unknown output %v", txIn.PreviousOutPoint)
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, preInput.TxOut[txIn.PreviousOutPoint.Index])
+	}
+	return fetcher, nil
+}
+
+func postTransaction(backend ChainBackend, tx *wire.MsgTx) (string, error) {
+	var buffer bytes.Buffer
+	err := tx.Serialize(&buffer)
+	if err != nil {
+		return "", err
+	}
+	return backend.PostTransaction(hex.EncodeToString(buffer.Bytes()))
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+// memoryBackend is an in-memory ChainBackend, every known transaction is
+// treated as confirmed at the tip it was added at, unless posted while
+// unconfirmed is set.
+type memoryBackend struct {
+	net     *chaincfg.Params
+	txs     map[chainhash.Hash]*wire.MsgTx
+	heights map[chainhash.Hash]int
+	order   []chainhash.Hash
+	posted  []*wire.MsgTx
+	tip     int64
+	// unconfirmed leaves the transactions posted from now on in the mempool
+	unconfirmed bool
+	mempool     map[chainhash.Hash]bool
+	// feerates are the fee estimates by confirmation target
+	feerates map[int]float64
+}
+
+func newMemoryBackend(net *chaincfg.Params) *memoryBackend {
+	return &memoryBackend{net: net, txs: make(map[chainhash.Hash]*wire.MsgTx), heights: make(map[chainhash.Hash]int), mempool: make(map[chainhash.Hash]bool)}
+}
+
+func (b *memoryBackend) addTx(tx *wire.MsgTx) {
+	hash := tx.TxHash()
+	if _, ok := b.txs[hash]; !ok {
+		b.order = append(b.order, hash)
+		b.heights[hash] = int(b.tip)
+	}
+	b.txs[hash] = tx
+}
+
+// fund adds a transaction paying each of values to address and returns it.
+func (b *memoryBackend) fund(t *testing.T, address string, values ...int64) *wire.MsgTx {
+	t.Helper()
+	addr, err := decodeAddress(address, b.net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(2)
+	// funding transactions are coinbases, which have no parents to trace, a
+	// unique script keeps them distinct
+	coinbase := wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte(fmt.Sprintf("%s-%d", address, len(b.txs))), nil)
+	tx.AddTxIn(coinbase)
+	for _, value := range values {
+		tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	b.addTx(tx)
+	return tx
+}
+
+// fundInscription adds a reveal of an inscription on the first sat of an output
+// of value to address and returns the inscription id.
+func (b *memoryBackend) fundInscription(t *testing.T, address string, value int64) ordinal.InscriptionID {
+	t.Helper()
+	tx := b.fund(t, address, value)
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the witness does not change the txid, only the envelope in it matters
+	controlBlock := append([]byte{byte(txscript.BaseLeafVersion)}, schnorr.SerializePubKey(key.PubKey())...)
+	tx.TxIn[0].Witness = wire.TxWitness{make([]byte, 64), inscriptionScript(key.PubKey(), "text/plain", []byte("ord")), controlBlock}
+	return ordinal.InscriptionID{Txid: tx.TxHash()}
+}
+
+func (b *memoryBackend) spent(outpoint wire.OutPoint) bool {
+	for _, tx := range b.txs {
+		for _, txIn := range tx.TxIn {
+			if txIn.PreviousOutPoint == outpoint {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (b *memoryBackend) GetBalance(address string) (int64, error) {
+	utxos, err := b.GetUnspentUtxo(address)
+	if err != nil {
+		return 0, err
+	}
+	balance := int64(0)
+	for _, utxo := range utxos {
+		balance += int64(utxo.Value)
+	}
+	return balance, nil
+}
+
+func (b *memoryBackend) GetUnspentUtxo(address string) ([]*unspentUtxo, error) {
+	addr, err := decodeAddress(address, b.net)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]*unspentUtxo, 0)
+	for _, hash := range b.order {
+		for vout, txOut := range b.txs[hash].TxOut {
+			if !bytes.Equal(txOut.PkScript, pkScript) || b.spent(*wire.NewOutPoint(&hash, uint32(vout))) {
+				continue
+			}
+			utxo := &unspentUtxo{Txid: hash.String(), Vout: vout, Value: int(txOut.Value)}
+			if !b.mempool[hash] {
+				utxo.Status.Confirmed = true
+				utxo.Status.BlockHeight = b.heights[hash]
+			}
+			utxos = append(utxos, utxo)
+		}
+	}
+	return utxos, nil
+}
+
+func (b *memoryBackend) GetRawTransaction(txid string) (string, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return "", err
+	}
+	tx, ok := b.txs[*hash]
+	if !ok {
+		return "", fmt.Errorf("transaction not found: %s", txid)
+	}
+	var buffer bytes.Buffer
+	if err := tx.Serialize(&buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer.Bytes()), nil
+}
+
+func (b *memoryBackend) PostTransaction(raw string) (string, error) {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", err
+	}
+	tx := &wire.MsgTx{}
+	if err := tx.Deserialize(bytes.NewReader(data)); err != nil {
+		return "", err
+	}
+	b.addTx(tx)
+	b.posted = append(b.posted, tx)
+	if b.unconfirmed {
+		b.mempool[tx.TxHash()] = true
+	}
+	return tx.TxHash().String(), nil
+}
+
+func (b *memoryBackend) GetBlockHeight() (int64, error) {
+	return b.tip, nil
+}
+
+func (b *memoryBackend) GetRawBlock(height int64) ([]byte, error) {
+	return nil, fmt.Errorf("memory backend has no blocks")
+}
+
+func (b *memoryBackend) EstimateFeeRate(target int) (float64, error) {
+	feerate, ok := b.feerates[target]
+	if !ok {
+		return 0, fmt.Errorf("no fee estimate for %d blocks", target)
+	}
+	return feerate, nil
+}
+
+// verifyTx runs every input of tx through the script engine against its prevouts in backend.
+func verifyTx(t *testing.T, backend *memoryBackend, tx *wire.MsgTx) {
+	t.Helper()
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for _, txIn := range tx.TxIn {
+		prevTx, ok := backend.txs[txIn.PreviousOutPoint.Hash]
+		if !ok {
+			t.Fatalf("unknown prevout %v", txIn.PreviousOutPoint)
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, prevTx.TxOut[txIn.PreviousOutPoint.Index])
+	}
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes, prevOut.Value, fetcher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.Execute(); err != nil {
+			t.Fatalf("input %d: %v", i, err)
+		}
+	}
+}
+
+// checkFeeRate checks tx pays feerate, give or take the slack of signatures
+// shorter than the estimate.
+func checkFeeRate(t *testing.T, backend *memoryBackend, tx *wire.MsgTx, feerate int64) {
+	t.Helper()
+	fee := int64(0)
+	for _, txIn := range tx.TxIn {
+		fee += backend.txs[txIn.PreviousOutPoint.Hash].TxOut[txIn.PreviousOutPoint.Index].Value
+	}
+	for _, txOut := range tx.TxOut {
+		fee -= txOut.Value
+	}
+	vsize := (blockchain.GetTransactionWeight(btcutil.NewTx(tx)) + 3) / 4
+	// a shorter signature saves under a vbyte in a witness, and a byte in a
+	// scriptSig, whose length prefix can shrink by two more
+	slack := int64(len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		if pushes, err := txscript.PushedData(txIn.SignatureScript); err == nil && len(pushes) > 0 {
+			slack += int64(len(pushes)) + 2
+		}
+	}
+	if fee < vsize*feerate || fee > (vsize+slack)*feerate {
+		t.Fatalf("fee %d for %d vbytes at %d sat/vB", fee, vsize, feerate)
+	}
+}
+
+func newTestWIFs(t *testing.T, net *chaincfg.Params, n int) []*btcutil.WIF {
+	t.Helper()
+	wifs := make([]*btcutil.WIF, 0, n)
+	for i := 0; i < n; i++ {
+		privkey, err := btcec.NewPrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wif, err := btcutil.NewWIF(privkey, net, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wifs = append(wifs, wif)
+	}
+	return wifs
+}
+
+func Test_SendSatoshi(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, from, 5000, 100000)
+	tx, err := sendSatoshi(from, wifs[0], from, 2000, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if tx.TxOut[0].Value != 2000 {
+		t.Fatalf("send value: %d", tx.TxOut[0].Value)
+	}
+}
+
+func Test_SendSatoshiSeveralInputs(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no single coin pays 10000
+	backend.fund(t, from, 4000, 4000, 4000, 3000)
+	tx, err := sendSatoshi(from, wifs[0], from, 10000, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if len(tx.TxIn) < 3 {
+		t.Fatalf("spent %d inputs", len(tx.TxIn))
+	}
+	if _, err := sendSatoshi(from, wifs[0], from, 20000, 2, &coinSource{strategy: coinselect.Select}, backend, net); !errors.Is(err, coinselect.ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+}
+
+// newTestPolicy builds a threshold-of-len(wifs) policy of wifs paid to as
+// scriptType, keeping only the public keys of the cosigners in pubKeyOnly.
+func newTestPolicy(t *testing.T, scriptType string, threshold int, wifs []*btcutil.WIF, pubKeyOnly ...int) *multisigPolicy {
+	cosigners := make([]*cosigner, 0, len(wifs))
+	for i, wif := range wifs {
+		c := &cosigner{name: fmt.Sprintf("key%d", i), pubKey: wif.SerializePubKey(), wif: wif}
+		if slices.Contains(pubKeyOnly, i) {
+			c.wif = nil
+		}
+		cosigners = append(cosigners, c)
+	}
+	policy, err := newMultisigPolicy(threshold, cosigners, scriptType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func Test_SendInscriptionFromMultisig(t *testing.T) {
+	tests := []struct {
+		name       string
+		scriptType string
+		threshold  int
+		keys       int
+		pubKeyOnly []int
+	}{
+		{name: "2-of-3", scriptType: multisigP2SH, threshold: 2, keys: 3},
+		{name: "3-of-5 with pubkey-only cosigners", scriptType: multisigP2SH, threshold: 3, keys: 5, pubKeyOnly: []int{0, 3}},
+		{name: "p2wsh 2-of-3", scriptType: multisigP2WSH, threshold: 2, keys: 3},
+		{name: "p2sh-p2wsh 3-of-5", scriptType: multisigP2SHP2WSH, threshold: 3, keys: 5, pubKeyOnly: []int{4}},
+		{name: "p2tr musig2 key path", scriptType: multisigP2TR, threshold: 2, keys: 3},
+		{name: "p2tr multi_a leaf", scriptType: multisigP2TR, threshold: 2, keys: 3, pubKeyOnly: []int{0}},
+		{name: "p2tr multi_a leaf 3-of-5", scriptType: multisigP2TR, threshold: 3, keys: 5, pubKeyOnly: []int{2, 4}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			net := &chaincfg.RegressionNetParams
+			backend := newMemoryBackend(net)
+			wifs := newTestWIFs(t, net, test.keys)
+			policy := newTestPolicy(t, test.scriptType, test.threshold, wifs, test.pubKeyOnly...)
+			from, err := multisigSpender(policy, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			multiAddress := from.address
+			feeAddress, err := policy.cosignerAddress(1, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inscriptionId := backend.fundInscription(t, multiAddress, 546)
+			backend.fund(t, feeAddress, 50000)
+
+			tx, err := createTx(from, feeAddress, inscriptionId, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err = signGasInput(tx, wifs[1], 1, backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx, err = from.signInput(tx, 0, backend)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyTx(t, backend, tx)
+			checkFeeRate(t, backend, tx, 2)
+			if _, err := postTransaction(backend, tx); err != nil {
+				t.Fatal(err)
+			}
+			if balance, _ := backend.GetBalance(multiAddress); balance != 0 {
+				t.Fatalf("multisig balance after send: %d", balance)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// commitSeedTag tags the hash turning a funding key into the seed of its commit keys.
+var commitSeedTag = []byte("brc20tools/commit")
+
+// commitKeyPath is the BIP32 path of the commit keys under the commit seed,
+// m/86'/coin'/0'/0 as in BIP86, the commit key index appended.
+func commitKeyPath(net *chaincfg.Params) []uint32 {
+	return []uint32{
+		hdkeychain.HardenedKeyStart + 86,
+		hdkeychain.HardenedKeyStart + net.HDCoinType,
+		hdkeychain.HardenedKeyStart,
+		0,
+	}
+}
+
+// commitKey derives the commit key at index from the funding key wif, so a
+// commit output it funded can be found again by scanning indexes.
+func commitKey(wif *btcutil.WIF, index uint32, net *chaincfg.Params) (*btcec.PrivateKey, error) {
+	seed := chainhash.TaggedHash(commitSeedTag, wif.PrivKey.Serialize())
+	key, err := hdkeychain.NewMaster(seed[:], net)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range append(commitKeyPath(net), index) {
+		key, err = key.Derive(child)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key.ECPrivKey()
+}
+
+// foundCommit is an unspent commit output found by scanning commit keys.
+type foundCommit struct {
+	index    uint32
+	key      *btcec.PrivateKey
+	root     chainhash.Hash
+	outpoint *wire.OutPoint
+	prevOut  *wire.TxOut
+}
+
+// scanCommits looks for unspent outputs to the commit addresses of wif from
+// index start, rebuilt from the leaf hashes the journal recorded and, when body
+// is given, from the inscription of body under each key. It stops after gap
+// indexes in a row with neither a recorded leaf nor an unspent output.
+func scanCommits(wif *btcutil.WIF, leaves map[uint32]chainhash.Hash, contentType string, body []byte, start uint32, gap uint32, backend ChainBackend, net *chaincfg.Params) ([]*foundCommit, error) {
+	found := make([]*foundCommit, 0)
+	for index, misses := start, uint32(0); misses < gap; index++ {
+		key, err := commitKey(wif, index, net)
+		if err != nil {
+			return nil, err
+		}
+		roots := make([]chainhash.Hash, 0, 2)
+		leaf, used := leaves[index]
+		if used {
+			roots = append(roots, leaf)
+		}
+		if body != nil {
+			script := inscriptionScript(key.PubKey(), contentType, body)
+			if root := txscript.NewBaseTapLeaf(script).TapHash(); !used || root != leaf {
+				roots = append(roots, root)
+			}
+		}
+		for _, root := range roots {
+			commitAddress, err := tapLeafAddress(key.PubKey(), root, net)
+			if err != nil {
+				return nil, err
+			}
+			utxos, err := backend.GetUnspentUtxo(commitAddress)
+			if err != nil {
+				return nil, err
+			}
+			for _, utxo := range utxos {
+				tx, err := getTransction(backend, utxo.Txid)
+				if err != nil {
+					return nil, err
+				}
+				hash := tx.TxHash()
+				found = append(found, &foundCommit{
+					index:    index,
+					key:      key,
+					root:     root,
+					outpoint: wire.NewOutPoint(&hash, uint32(utxo.Vout)),
+					prevOut:  tx.TxOut[utxo.Vout],
+				})
+				used = true
+			}
+		}
+		if used {
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	return found, nil
+}
+
+func recoverCommits(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	from, wif, err := getPayer(cli, policy, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	j, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	leaves, err := j.commitLeafHashes()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	if cli.IsSet("body") {
+		body = []byte(cli.String("body"))
+	}
+	if len(leaves) == 0 && body == nil {
+		return fmt.Errorf("the journal records no commit leaves, pass the --body of the inscription to rebuild them")
+	}
+	found, err := scanCommits(wif, leaves, cli.String("content-type"), body, uint32(cli.Int("start")), uint32(cli.Int("gap")), backend, net)
+	if err != nil {
+		return err
+	}
+	for _, commit := range found {
+		tx, err := sweepCommitOutput(commit.key, commit.root, commit.outpoint, commit.prevOut, from, feerate, backend, net)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("swept commit key %d %v to %s: %s\n", commit.index, commit.outpoint, from, tx.TxHash())
+	}
+	if len(found) == 0 {
+		fmt.Println("no unspent commit outputs")
+	}
+	return nil
+}
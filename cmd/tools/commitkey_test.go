@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+// Test_RecoverCommit loses the reveal, then finds the commit output again by
+// scanning commit key indexes, from the journal leaves or without the journal
+// from the body, and sweeps it back.
+func Test_RecoverCommit(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, from, 80000)
+
+	first, err := commitKey(wifs[0], 3, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := commitKey(wifs[0], 3, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := commitKey(wifs[0], 4, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Key.Equals(&again.Key) || first.Key.Equals(&next.Key) {
+		t.Fatal("commit keys not derived by index")
+	}
+
+	j := newTestJournal(t)
+	j.NextCommitIndex = 5
+	source := &coinSource{strategy: coinselect.Select}
+	flaky := &flakyBackend{memoryBackend: backend, quota: 1}
+	body := []byte(`{"p":"brc-20","op":"mint","tick":"ordi","amt":"1"}`)
+	if _, err := inscribe(from, wifs[0], from, "text/plain", body, 546, 2, source, j, flaky, net); err == nil {
+		t.Fatal("inscribed with the reveal lost")
+	}
+
+	leaves, err := j.commitLeafHashes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := scanCommits(wifs[0], leaves, "", nil, 0, 10, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].index != 5 {
+		t.Fatalf("found %d commits from the journal leaves", len(found))
+	}
+	// with the journal gone, the body rebuilds the leaf
+	found, err = scanCommits(wifs[0], nil, "text/plain", body, 0, 10, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].index != 5 {
+		t.Fatalf("found %d commits from the body", len(found))
+	}
+	if found, _ := scanCommits(wifs[0], nil, "text/plain", body, 0, 5, backend, net); len(found) != 0 {
+		t.Fatal("scanned past the gap limit")
+	}
+	j.NextCommitIndex = 0
+	if index := j.nextCommitIndex(); index != 6 {
+		t.Fatalf("commit index %d handed out again", index)
+	}
+	tx, err := sweepCommitOutput(found[0].key, found[0].root, found[0].outpoint, found[0].prevOut, from, 2, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"brc20tools/localindex"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// dryRunBackend reads the chain from its ChainBackend but keeps the
+// transactions posted to it, describing them to w instead of broadcasting.
+// Later reads see the kept transactions, so a command posting a chain of
+// transactions builds each on the previous ones as it would for real.
+type dryRunBackend struct {
+	ChainBackend
+	// indexer, when set, tells where the inscriptions the described transactions move are
+	indexer BRC20Indexer
+	net     *chaincfg.Params
+	w       io.Writer
+	txs     map[chainhash.Hash]*wire.MsgTx
+	// order keeps the posting order, for the utxos created by the kept transactions
+	order []chainhash.Hash
+}
+
+func newDryRunBackend(backend ChainBackend, indexer BRC20Indexer, w io.Writer, net *chaincfg.Params) *dryRunBackend {
+	return &dryRunBackend{ChainBackend: backend, indexer: indexer, net: net, w: w, txs: make(map[chainhash.Hash]*wire.MsgTx)}
+}
+
+func (b *dryRunBackend) GetRawTransaction(txid string) (string, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return "", err
+	}
+	if tx, ok := b.txs[*hash]; ok {
+		return txHex(tx)
+	}
+	return b.ChainBackend.GetRawTransaction(txid)
+}
+
+// GetUnspentUtxo returns the utxos of address less those the kept transactions
+// spend, plus those they create.
+func (b *dryRunBackend) GetUnspentUtxo(address string) ([]*unspentUtxo, error) {
+	utxos, err := b.ChainBackend.GetUnspentUtxo(address)
+	if err != nil {
+		return nil, err
+	}
+	spent := make(map[wire.OutPoint]bool)
+	for _, tx := range b.txs {
+		for _, txIn := range tx.TxIn {
+			spent[txIn.PreviousOutPoint] = true
+		}
+	}
+	unspent := make([]*unspentUtxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		hash, err := chainhash.NewHashFromStr(utxo.Txid)
+		if err != nil {
+			return nil, err
+		}
+		if !spent[*wire.NewOutPoint(hash, uint32(utxo.Vout))] {
+			unspent = append(unspent, utxo)
+		}
+	}
+	addr, err := decodeAddress(address, b.net)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	for _, hash := range b.order {
+		for vout, txOut := range b.txs[hash].TxOut {
+			if !bytes.Equal(txOut.PkScript, pkScript) || spent[*wire.NewOutPoint(&hash, uint32(vout))] {
+				continue
+			}
+			unspent = append(unspent, &unspentUtxo{Txid: hash.String(), Vout: vout, Value: int(txOut.Value)})
+		}
+	}
+	return unspent, nil
+}
+
+func (b *dryRunBackend) GetBalance(address string) (int64, error) {
+	utxos, err := b.GetUnspentUtxo(address)
+	if err != nil {
+		return 0, err
+	}
+	balance := int64(0)
+	for _, utxo := range utxos {
+		balance += int64(utxo.Value)
+	}
+	return balance, nil
+}
+
+// PostTransaction describes the transaction and keeps it without broadcasting.
+func (b *dryRunBackend) PostTransaction(raw string) (string, error) {
+	tx, err := decodeTxHex(raw)
+	if err != nil {
+		return "", err
+	}
+	if err := describeTx(b.w, tx, b.indexer, b, b.net); err != nil {
+		return "", err
+	}
+	hash := tx.TxHash()
+	if _, ok := b.txs[hash]; !ok {
+		b.order = append(b.order, hash)
+	}
+	b.txs[hash] = tx
+	fmt.Fprintf(b.w, "dry run, not broadcast: %s\n%s\n\n", hash, raw)
+	return hash.String(), nil
+}
+
+// describeTx writes the inputs and outputs of tx, its fee, size and fee rate,
+// and where the inscriptions it moves or reveals land.
+func describeTx(w io.Writer, tx *wire.MsgTx, indexer BRC20Indexer, backend ChainBackend, net *chaincfg.Params) error {
+	fetcher, err := prevOutFetcher(tx, backend)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "transaction %s\n", tx.TxHash())
+	inputsValue := int64(0)
+	inputs := table.NewWriter()
+	inputs.SetOutputMirror(w)
+	inputs.AppendHeader(table.Row{"#", "Outpoint", "Address", "Satoshi"})
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		inputsValue += prevOut.Value
+		inputs.AppendRow(table.Row{i, txIn.PreviousOutPoint.String(), pkScriptAddress(prevOut.PkScript, net), prevOut.Value})
+	}
+	inputs.Render()
+
+	outputsValue := int64(0)
+	outputs := table.NewWriter()
+	outputs.SetOutputMirror(w)
+	outputs.AppendHeader(table.Row{"#", "Address", "Satoshi"})
+	for i, txOut := range tx.TxOut {
+		outputsValue += txOut.Value
+		outputs.AppendRow(table.Row{i, pkScriptAddress(txOut.PkScript, net), txOut.Value})
+	}
+	outputs.Render()
+
+	fee := inputsValue - outputsValue
+	vsize := mempool.GetTxVirtualSize(btcutil.NewTx(tx))
+	fmt.Fprintf(w, "fee: %d sat, vsize: %d vB, fee rate: %.1f sat/vB\n", fee, vsize, float64(fee)/float64(vsize))
+
+	// the inscriptions carried by the inputs, wherever they came from
+	tracer := newInscriptionTracer(indexer, backend, net)
+	offset := int64(0)
+	offsets := make([]int64, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		offsets[i] = offset
+		held, err := tracer.inscriptions(txIn.PreviousOutPoint, traceDepth)
+		if err != nil {
+			// the summary goes on, the transaction is what it is
+			fmt.Fprintf(w, "inscriptions on input %d unknown: %v\n", i, err)
+		}
+		for _, h := range held {
+			satpoint := ordinal.Satpoint{Outpoint: ordinal.FromWire(txIn.PreviousOutPoint), Offset: uint64(h.offset)}
+			fmt.Fprintf(w, "inscription %v on %v: input %d -> %s\n", h.id, satpoint, i, landing(tx.TxOut, offset+h.offset, net))
+		}
+		offset += fetcher.FetchPrevOutput(txIn.PreviousOutPoint).Value
+	}
+	for n, envelope := range localindex.ParseEnvelopes(tx) {
+		at := offsets[envelope.Input]
+		if envelope.Pointer != nil && int64(*envelope.Pointer) < outputsValue {
+			at = int64(*envelope.Pointer)
+		}
+		fmt.Fprintf(w, "inscription %si%d %s: input %d -> %s\n", tx.TxHash(), n, envelope.ContentType, envelope.Input, landing(tx.TxOut, at, net))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+// Test_DryRunInscribe runs a whole inscription against a dry run backend,
+// which must describe the commit and the reveal and broadcast neither.
+func Test_DryRunInscribe(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funding := backend.fund(t, from, 80000)
+	j := newTestJournal(t)
+	j.dryRun = true
+
+	var summary bytes.Buffer
+	dryRun := newDryRunBackend(backend, nil, &summary, net)
+	inscriptionId, err := inscribe(from, wifs[0], from, "text/plain", []byte("ord"), 546, 2, &coinSource{strategy: coinselect.Select}, j, dryRun, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.posted) != 0 {
+		t.Fatalf("dry run broadcast %d transactions", len(backend.posted))
+	}
+	if _, err := os.Stat(j.path); !os.IsNotExist(err) {
+		t.Fatalf("dry run saved the journal: %v", err)
+	}
+	for _, expected := range []string{
+		fmt.Sprintf("inscription %s text/plain: input 0 -> output 0 %s", inscriptionId, from),
+		"fee rate: 2.",
+		"dry run, not broadcast: " + inscriptionId[:64],
+	} {
+		if !strings.Contains(summary.String(), expected) {
+			t.Fatalf("summary misses %q:\n%s", expected, summary.String())
+		}
+	}
+
+	// the dry run change is spendable by the next dry run transaction
+	utxos, err := dryRun.GetUnspentUtxo(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) == 0 {
+		t.Fatal("no dry run change")
+	}
+	for _, utxo := range utxos {
+		if utxo.Txid == funding.TxHash().String() {
+			t.Fatalf("utxo %s spent by the dry run", utxoOutpoint(utxo))
+		}
+	}
+}
+
+// Test_DescribeTransferredInscription describes a spend of an inscription the
+// parent of the input did not reveal, but received from an earlier owner.
+func Test_DescribeTransferredInscription(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 2)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := bitcoin.PubKeyToAddr(wifs[1].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionId := backend.fundInscription(t, other, 10000)
+	funding := backend.fund(t, from, 20000)
+	fundingHash := funding.TxHash()
+
+	// other sends the inscription to from on sat 400 of its output
+	transfer := wire.NewMsgTx(2)
+	transfer.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 0), nil, nil))
+	transfer.AddTxIn(wire.NewTxIn(ordinal.Outpoint{Txid: inscriptionId.Txid}.Wire(), nil, nil))
+	transfer.AddTxOut(wire.NewTxOut(19600, funding.TxOut[0].PkScript))
+	transfer.AddTxOut(wire.NewTxOut(10400, funding.TxOut[0].PkScript))
+	backend.addTx(transfer)
+	transferHash := transfer.TxHash()
+
+	spend := wire.NewMsgTx(2)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&transferHash, 1), nil, nil))
+	spend.AddTxOut(wire.NewTxOut(1000, funding.TxOut[0].PkScript))
+	spend.AddTxOut(wire.NewTxOut(9000, funding.TxOut[0].PkScript))
+	var summary bytes.Buffer
+	if err := describeTx(&summary, spend, nil, backend, net); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("inscription %v on %s:1:400: input 0 -> output 0 %s", inscriptionId, transferHash, from)
+	if !strings.Contains(summary.String(), expected) {
+		t.Fatalf("summary misses %q:\n%s", expected, summary.String())
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+const electrumProtocolVersion = "1.4"
+
+// electrumBackend is a ChainBackend on an Electrum protocol server, the calls
+// sharing one connection dialed by the first and dialed again after an error.
+type electrumBackend struct {
+	server  string
+	useTLS  bool
+	net     *chaincfg.Params
+	timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	nextID int
+}
+
+func newElectrumBackend(server string, useTLS bool, net *chaincfg.Params) *electrumBackend {
+	return &electrumBackend{
+		server:  server,
+		useTLS:  useTLS,
+		net:     net,
+		timeout: 30 * time.Second,
+	}
+}
+
+type electrumRequest struct {
+	JsonRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type electrumResponse struct {
+	// ID is nil on subscription notifications
+	ID     *int            `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *electrumBackend) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: b.timeout}
+	if b.useTLS {
+		return tls.DialWithDialer(dialer, "tcp", b.server, &tls.Config{})
+	}
+	return dialer.Dial("tcp", b.server)
+}
+
+func (b *electrumBackend) call(method string, result interface{}, params ...interface{}) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		conn, err := b.dial()
+		if err != nil {
+			return err
+		}
+		b.conn, b.reader = conn, bufio.NewReader(conn)
+		if err := b.roundTrip("server.version", nil, "brc20tools", electrumProtocolVersion); err != nil {
+			b.close()
+			return err
+		}
+	}
+	if err := b.roundTrip(method, result, params...); err != nil {
+		b.close()
+		return err
+	}
+	return nil
+}
+
+// close drops the connection, the next call dialing a new one.
+func (b *electrumBackend) close() {
+	b.conn.Close()
+	b.conn, b.reader = nil, nil
+}
+
+// roundTrip sends a request on the connection and reads its response into
+// result, skipping notifications.
+func (b *electrumBackend) roundTrip(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	id := b.nextID
+	b.nextID++
+	b.conn.SetDeadline(time.Now().Add(b.timeout))
+	if err := json.NewEncoder(b.conn).Encode(&electrumRequest{JsonRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		return err
+	}
+	for {
+		line, err := b.reader.ReadBytes('\n')
+		if err != nil {
+			return err
+		}
+		response := &electrumResponse{}
+		if err := json.Unmarshal(line, response); err != nil {
+			return err
+		}
+		if response.ID == nil {
+			continue
+		}
+		if *response.ID != id {
+			return fmt.Errorf("%s: response to request %d, expected %d", method, *response.ID, id)
+		}
+		if response.Error != nil {
+			return fmt.Errorf("%s: %s (%d)", method, response.Error.Message, response.Error.Code)
+		}
+		if result == nil {
+			return nil
+		}
+		return json.Unmarshal(response.Result, result)
+	}
+}
+
+// scriptHash returns the electrum script hash of address, the reversed sha256 of its output script.
+func (b *electrumBackend) scriptHash(address string) (string, error) {
+	addr, err := decodeAddress(address, b.net)
+	if err != nil {
+		return "", err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(pkScript)
+	for i, j := 0, len(hash)-1; i < j; i, j = i+1, j-1 {
+		hash[i], hash[j] = hash[j], hash[i]
+	}
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func (b *electrumBackend) GetBalance(address string) (int64, error) {
+	scriptHash, err := b.scriptHash(address)
+	if err != nil {
+		return 0, err
+	}
+	result := &struct {
+		Confirmed   int64 `json:"confirmed"`
+		Unconfirmed int64 `json:"unconfirmed"`
+	}{}
+	err = b.call("blockchain.scripthash.get_balance", result, scriptHash)
+	return result.Confirmed, err
+}
+
+func (b *electrumBackend) GetUnspentUtxo(address string) ([]*unspentUtxo, error) {
+	scriptHash, err := b.scriptHash(address)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]struct {
+		TxHash string `json:"tx_hash"`
+		TxPos  int    `json:"tx_pos"`
+		Height int    `json:"height"`
+		Value  int    `json:"value"`
+	}, 0)
+	err = b.call("blockchain.scripthash.listunspent", &result, scriptHash)
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]*unspentUtxo, 0, len(result))
+	for _, unspent := range result {
+		utxo := &unspentUtxo{Txid: unspent.TxHash, Vout: unspent.TxPos, Value: unspent.Value}
+		utxo.Status.Confirmed = unspent.Height > 0
+		utxo.Status.BlockHeight = unspent.Height
+		utxos = append(utxos, utxo)
+	}
+	return utxos, nil
+}
+
+func (b *electrumBackend) GetRawTransaction(txid string) (string, error) {
+	var raw string
+	err := b.call("blockchain.transaction.get", &raw, txid)
+	return raw, err
+}
+
+func (b *electrumBackend) PostTransaction(raw string) (string, error) {
+	var txid string
+	err := b.call("blockchain.transaction.broadcast", &txid, raw)
+	return txid, err
+}
+
+func (b *electrumBackend) GetBlockHeight() (int64, error) {
+	result := &struct {
+		Height int64 `json:"height"`
+	}{}
+	err := b.call("blockchain.headers.subscribe", result)
+	return result.Height, err
+}
+
+// GetRawBlock is not part of the electrum protocol, which only serves headers.
+func (b *electrumBackend) GetRawBlock(height int64) ([]byte, error) {
+	return nil, fmt.Errorf("electrum backend does not serve blocks, index from bitcoind, esplora or --blocks-dir")
+}
+
+func (b *electrumBackend) EstimateFeeRate(target int) (float64, error) {
+	var feerate float64
+	if err := b.call("blockchain.estimatefee", &feerate, target); err != nil {
+		return 0, err
+	}
+	if feerate <= 0 {
+		return 0, fmt.Errorf("electrum has no fee estimate within %d blocks", target)
+	}
+	// BTC/kB to sat/vB
+	return feerate * 1e8 / 1000, nil
+}
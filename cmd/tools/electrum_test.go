@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Test_ElectrumConnection shares one connection between calls, skips
+// notifications, and refuses a response to another request without
+// panicking, dialing again after it.
+func Test_ElectrumConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	dials := int32(0)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&dials, 1)
+			go func(conn net.Conn) {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					request := &electrumRequest{}
+					if err := json.Unmarshal(scanner.Bytes(), request); err != nil {
+						return
+					}
+					switch request.Method {
+					case "server.version":
+						fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"result":["test","1.4"]}`+"\n", request.ID)
+					case "blockchain.transaction.get":
+						fmt.Fprint(conn, `{"jsonrpc":"2.0","method":"blockchain.headers.subscribe","params":[{"height":1}]}`+"\n")
+						fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"result":"00"}`+"\n", request.ID)
+					default:
+						fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%d,"result":"00"}`+"\n", request.ID+7)
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	backend := newElectrumBackend(listener.Addr().String(), false, &chaincfg.RegressionNetParams)
+	for i := 0; i < 2; i++ {
+		if raw, err := backend.GetRawTransaction("00"); err != nil || raw != "00" {
+			t.Fatalf("raw transaction %q: %v", raw, err)
+		}
+	}
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Fatalf("%d connections for two calls", n)
+	}
+	if _, err := backend.PostTransaction("00"); err == nil {
+		t.Fatal("response to another request accepted")
+	}
+	if _, err := backend.GetRawTransaction("00"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&dials); n != 2 {
+		t.Fatalf("%d connections, expected a new one after the error", n)
+	}
+}
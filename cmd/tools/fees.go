@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+)
+
+// feePriorities are the confirmation targets, in blocks, of the --priority presets.
+var feePriorities = map[string]int{
+	"fastest":  1,
+	"halfhour": 3,
+	"hour":     6,
+	"economy":  144,
+}
+
+// getFeeRate returns the fee rate in sat/vB given by --fee-rate, or else estimated
+// by backend for --priority and kept within --min-fee-rate and --max-fee-rate.
+func getFeeRate(cmd *cli.Command, backend ChainBackend) (int64, error) {
+	feerate, err := chooseFeeRate(cmd.Int("fee-rate"), cmd.String("priority"), cmd.Int("min-fee-rate"), cmd.Int("max-fee-rate"), backend)
+	if err != nil {
+		return 0, err
+	}
+	log.Printf("fee rate: %d sat/vB", feerate)
+	return feerate, nil
+}
+
+// chooseFeeRate returns feerate when it is set and within [floor, ceiling], or
+// else the estimate for priority clamped to [floor, ceiling].
+func chooseFeeRate(feerate int64, priority string, floor int64, ceiling int64, backend ChainBackend) (int64, error) {
+	if floor < 1 || ceiling < floor {
+		return 0, fmt.Errorf("invalid fee rate bounds [%d, %d]", floor, ceiling)
+	}
+	if feerate != 0 {
+		if feerate < floor || feerate > ceiling {
+			return 0, fmt.Errorf("fee rate %d out of bounds [%d, %d]", feerate, floor, ceiling)
+		}
+		return feerate, nil
+	}
+	target, ok := feePriorities[strings.ToLower(priority)]
+	if !ok {
+		return 0, fmt.Errorf("unknown priority: %s", priority)
+	}
+	estimate, err := backend.EstimateFeeRate(target)
+	if err != nil {
+		return 0, fmt.Errorf("estimate %s fee rate: %w", priority, err)
+	}
+	feerate = int64(math.Ceil(estimate))
+	if feerate > ceiling {
+		log.Printf("estimated fee rate %d capped at %d sat/vB", feerate, ceiling)
+	}
+	return min(max(feerate, floor), ceiling), nil
+}
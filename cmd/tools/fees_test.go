@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_ChooseFeeRate(t *testing.T) {
+	backend := newMemoryBackend(&chaincfg.RegressionNetParams)
+	backend.feerates = map[int]float64{1: 250.3, 3: 12.2, 6: 0.4}
+	tests := []struct {
+		feerate  int64
+		priority string
+		want     int64
+		fail     bool
+	}{
+		{feerate: 7, priority: "fastest", want: 7},
+		{feerate: 80, fail: true},
+		{priority: "halfhour", want: 13},
+		// a spike is capped, a quiet mempool floored
+		{priority: "fastest", want: 50},
+		{priority: "hour", want: 1},
+		{priority: "economy", fail: true},
+		{priority: "soon", fail: true},
+	}
+	for _, test := range tests {
+		got, err := chooseFeeRate(test.feerate, test.priority, 1, 50, backend)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%d %s: expected an error, got %d", test.feerate, test.priority, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d %s: %v", test.feerate, test.priority, err)
+		} else if got != test.want {
+			t.Errorf("%d %s: got %d, want %d", test.feerate, test.priority, got, test.want)
+		}
+	}
+}
@@ -5,8 +5,133 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strings"
+
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/urfave/cli/v3"
 )
 
+// BRC20Indexer answers the brc20 state queries the tools need.
+type BRC20Indexer interface {
+	// GetBalance returns the ticker balance of address, zero balances when it holds none.
+	GetBalance(address string, ticker string) (*brc20Balance, error)
+	GetTransferableInscriptions(address string, ticker string) ([]*transferableInscription, error)
+	// GetTickerInfo returns the deploy state of ticker, nil when it is not deployed.
+	GetTickerInfo(ticker string) (*tickerInfo, error)
+	GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error)
+	// GetAddressInscriptions returns every inscription address holds, brc20 or not.
+	GetAddressInscriptions(address string) ([]*inscriptionInfo, error)
+}
+
+type brc20Balance struct {
+	Ticker           string
+	OverallBalance   string
+	TransferBalance  string
+	AvailableBalance string
+}
+
+type transferableInscription struct {
+	InscriptionId string
+	Ticker        string
+	Amount        string
+	Confirmations int
+}
+
+type tickerInfo struct {
+	Ticker        string
+	InscriptionId string
+	Max           string
+	Limit         string
+	Minted        string
+	Decimals      int
+}
+
+type inscriptionInfo struct {
+	InscriptionId string
+	Address       string
+	// Location is the current satpoint of the inscription, txid:vout:offset.
+	Location string
+	// OutputValue is the value of the output holding the inscription, zero
+	// where the indexer does not report it.
+	OutputValue int64
+	ContentType string
+}
+
+func zeroBalance(ticker string) *brc20Balance {
+	return &brc20Balance{Ticker: ticker, OverallBalance: "0", TransferBalance: "0", AvailableBalance: "0"}
+}
+
+// getIndexer builds the indexer chosen by --indexer for net, INDEXER_AUTH is its api key.
+func getIndexer(cmd *cli.Command, net *chaincfg.Params) (BRC20Indexer, error) {
+	name := strings.ToLower(cmd.String("indexer"))
+	if name == "local" {
+		return openLocalIndexer(cmd, net)
+	}
+	api, err := indexerAPI(name, net)
+	if err != nil {
+		return nil, err
+	}
+	auth := os.Getenv("INDEXER_AUTH")
+	switch name {
+	case "merlin":
+		return &merlinIndexer{api: api, auth: auth}, nil
+	case "unisat":
+		return &unisatIndexer{api: api, auth: auth}, nil
+	case "okx":
+		return &okxIndexer{api: api, auth: auth}, nil
+	default:
+		return nil, fmt.Errorf("unknown indexer: %s", name)
+	}
+}
+
+// indexerGet sends a GET to url with headers and decodes the json response into result.
+func indexerGet(url string, headers map[string]string, result interface{}) error {
+	client := &http.Client{}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("GET %s: %s: %s", url, res.Status, body)
+	}
+	return json.Unmarshal(body, result)
+}
+
+// indexerNotFound reports whether an indexer error response means the queried item does not exist.
+func indexerNotFound(code int, msg string) bool {
+	return code != 0 && strings.Contains(strings.ToLower(msg), "not found")
+}
+
+// merlinPageSize is the page size used for the list endpoints.
+const merlinPageSize = 100
+
+// merlinIndexer is the Merlin protocol brc20 indexer.
+type merlinIndexer struct {
+	api  string
+	auth string
+}
+
+func (m *merlinIndexer) get(path string, result interface{}) error {
+	headers := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", m.auth)}
+	return indexerGet(fmt.Sprintf("%s%s", m.api, path), headers, result)
+}
+
 type getBalanceResponse struct {
 	Code int    `json:"code"`
 	Msg  string `json:"msg"`
@@ -23,31 +148,26 @@ type getBalanceResponse struct {
 	} `json:"data"`
 }
 
-func getAddressSummary(address string, auth string) (*getBalanceResponse, error) {
-	url := fmt.Sprintf("https://testnet-api.merlinprotocol.org/apis/indexer/v1/address/%s/brc20/summary", address)
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
-
+func (m *merlinIndexer) GetBalance(address string, ticker string) (*brc20Balance, error) {
+	result := &getBalanceResponse{}
+	err := m.get(fmt.Sprintf("/address/%s/brc20/summary", address), result)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", auth))
-
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	if result.Code != 0 {
+		return nil, fmt.Errorf("getAddressSummary code: %d", result.Code)
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+	for _, item := range result.Data.Items {
+		if strings.EqualFold(item.Ticker, ticker) {
+			return &brc20Balance{
+				Ticker:           item.Ticker,
+				OverallBalance:   item.OverallBalance,
+				TransferBalance:  item.TransferBalance,
+				AvailableBalance: item.AvailableBalance,
+			}, nil
+		}
 	}
-	result := &getBalanceResponse{}
-	err = json.Unmarshal(body, result)
-	return result, err
+	return zeroBalance(ticker), nil
 }
 
 // {
@@ -94,29 +214,135 @@ type getTransferAbleInscriptionsResponse struct {
 	} `json:"data"`
 }
 
-func getTransferAbleInscriptions(address string, auth string) (*getTransferAbleInscriptionsResponse, error) {
-	url := fmt.Sprintf("https://testnet-api.merlinprotocol.org/apis/indexer/v1/address/%s/brc20/qwpo/transferable-inscriptions", address)
-	method := "GET"
+func (m *merlinIndexer) GetTransferableInscriptions(address string, ticker string) ([]*transferableInscription, error) {
+	inscriptions := make([]*transferableInscription, 0)
+	for {
+		result := &getTransferAbleInscriptionsResponse{}
+		path := fmt.Sprintf("/address/%s/brc20/%s/transferable-inscriptions?offset=%d&limit=%d", address, ticker, len(inscriptions), merlinPageSize)
+		err := m.get(path, result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Code != 0 {
+			return nil, fmt.Errorf("getInscriptions code: %d", result.Code)
+		}
+		for _, item := range result.Data.Inscriptions {
+			inscriptions = append(inscriptions, &transferableInscription{
+				InscriptionId: item.InscriptionId,
+				Ticker:        item.Data.Tick,
+				Amount:        item.Data.Amt,
+				Confirmations: item.Confirmations,
+			})
+		}
+		if len(result.Data.Inscriptions) == 0 || len(inscriptions) >= result.Data.Total {
+			return inscriptions, nil
+		}
+	}
+}
 
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
+type getTickerInfoResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data *struct {
+		Ticker        string `json:"ticker"`
+		InscriptionId string `json:"inscription_id"`
+		Max           string `json:"max"`
+		Limit         string `json:"limit"`
+		Minted        string `json:"minted"`
+		Decimal       int    `json:"decimal"`
+	} `json:"data"`
+}
 
+func (m *merlinIndexer) GetTickerInfo(ticker string) (*tickerInfo, error) {
+	result := &getTickerInfoResponse{}
+	err := m.get(fmt.Sprintf("/brc20/%s/info", ticker), result)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", auth))
+	if indexerNotFound(result.Code, result.Msg) || (result.Code == 0 && result.Data == nil) {
+		return nil, nil
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("getTickerInfo code: %d", result.Code)
+	}
+	return &tickerInfo{
+		Ticker:        result.Data.Ticker,
+		InscriptionId: result.Data.InscriptionId,
+		Max:           result.Data.Max,
+		Limit:         result.Data.Limit,
+		Minted:        result.Data.Minted,
+		Decimals:      result.Data.Decimal,
+	}, nil
+}
 
-	res, err := client.Do(req)
+type getInscriptionResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		InscriptionId string `json:"inscription_id"`
+		Address       string `json:"address"`
+		Location      string `json:"location"`
+		OutputValue   int64  `json:"output_value"`
+		ContentType   string `json:"content_type"`
+	} `json:"data"`
+}
+
+func (m *merlinIndexer) GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error) {
+	result := &getInscriptionResponse{}
+	err := m.get(fmt.Sprintf("/inscription/info/%s", inscriptionId), result)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
+	if result.Code != 0 {
+		return nil, fmt.Errorf("getInscription code: %d", result.Code)
+	}
+	return &inscriptionInfo{
+		InscriptionId: result.Data.InscriptionId,
+		Address:       result.Data.Address,
+		Location:      result.Data.Location,
+		OutputValue:   result.Data.OutputValue,
+		ContentType:   result.Data.ContentType,
+	}, nil
+}
 
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+type getAddressInscriptionsResponse struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data struct {
+		Total        int `json:"total"`
+		Offset       int `json:"offset"`
+		Inscriptions []struct {
+			InscriptionId string `json:"inscription_id"`
+			Address       string `json:"address"`
+			Location      string `json:"location"`
+			OutputValue   int64  `json:"output_value"`
+			ContentType   string `json:"content_type"`
+		} `json:"inscriptions"`
+	} `json:"data"`
+}
+
+func (m *merlinIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	inscriptions := make([]*inscriptionInfo, 0)
+	for {
+		result := &getAddressInscriptionsResponse{}
+		err := m.get(fmt.Sprintf("/address/%s/inscriptions?offset=%d&limit=%d", address, len(inscriptions), merlinPageSize), result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Code != 0 {
+			return nil, fmt.Errorf("getAddressInscriptions code: %d", result.Code)
+		}
+		for _, item := range result.Data.Inscriptions {
+			inscriptions = append(inscriptions, &inscriptionInfo{
+				InscriptionId: item.InscriptionId,
+				Address:       item.Address,
+				Location:      item.Location,
+				OutputValue:   item.OutputValue,
+				ContentType:   item.ContentType,
+			})
+		}
+		if len(result.Data.Inscriptions) == 0 || len(inscriptions) >= result.Data.Total {
+			return inscriptions, nil
+		}
 	}
-	result := &getTransferAbleInscriptionsResponse{}
-	err = json.Unmarshal(body, result)
-	return result, err
 }
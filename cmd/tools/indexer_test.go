@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"brc20tools/ordinal"
+)
+
+// newIndexerServer serves the json body routes returns for each request path,
+// a 404 for the paths it does not know.
+func newIndexerServer(t *testing.T, routes map[string]func(r *http.Request) (int, string)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		status, body := route(r)
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// queryInt is the integer query parameter name of r, 0 when missing.
+func queryInt(r *http.Request, name string) int {
+	value, _ := strconv.Atoi(r.URL.Query().Get(name))
+	return value
+}
+
+const testInscriptionId = "b8a95aebae6e845f9bbaefcd8c818677286945cd9c90ce3bc096430f13424c6di0"
+
+func Test_MerlinIndexer(t *testing.T) {
+	server := newIndexerServer(t, map[string]func(r *http.Request) (int, string){
+		"/address/bc1q/brc20/summary": func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":0,"msg":"success","data":{"total":1,"items":[{"ticker":"ORDI","overall_balance":"10","transfer_balance":"4","available_balance":"6"}]}}`
+		},
+		// three inscriptions served two per page
+		"/address/bc1q/brc20/ordi/transferable-inscriptions": func(r *http.Request) (int, string) {
+			offset := queryInt(r, "offset")
+			items := ""
+			for i := offset; i < 3 && i < offset+2; i++ {
+				if items != "" {
+					items += ","
+				}
+				items += fmt.Sprintf(`{"data":{"op":"transfer","amt":"%d","tick":"ordi"},"inscription_id":"%si%d","confirmations":1}`, i, testInscriptionId[:64], i)
+			}
+			return http.StatusOK, fmt.Sprintf(`{"code":0,"data":{"total":3,"offset":%d,"inscriptions":[%s]}}`, offset, items)
+		},
+		"/brc20/ordi/info": func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":0,"data":{"ticker":"ordi","inscription_id":"` + testInscriptionId + `","max":"21000000","limit":"1000","minted":"21000000","decimal":18}}`
+		},
+		"/brc20/none/info": func(r *http.Request) (int, string) {
+			return http.StatusNotFound, `{"code":-1,"msg":"ticker not found"}`
+		},
+		"/inscription/info/" + testInscriptionId: func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":-2,"msg":"internal error"}`
+		},
+		"/address/bc1q/inscriptions": func(r *http.Request) (int, string) {
+			return http.StatusInternalServerError, "down"
+		},
+	})
+	indexer := &merlinIndexer{api: server.URL}
+
+	balance, err := indexer.GetBalance("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.OverallBalance != "10" || balance.TransferBalance != "4" || balance.AvailableBalance != "6" {
+		t.Fatalf("balance %+v", balance)
+	}
+	inscriptions, err := indexer.GetTransferableInscriptions("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inscriptions) != 3 || inscriptions[2].Amount != "2" || inscriptions[2].InscriptionId != testInscriptionId[:64]+"i2" {
+		t.Fatalf("%d transferable inscriptions over two pages", len(inscriptions))
+	}
+	info, err := indexer.GetTickerInfo("ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Max != "21000000" || info.Decimals != 18 {
+		t.Fatalf("ticker info %+v", info)
+	}
+	if info, err := indexer.GetTickerInfo("none"); err != nil || info != nil {
+		t.Fatalf("unknown ticker: %+v, %v", info, err)
+	}
+	id, err := ordinal.ParseInscriptionID(testInscriptionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := indexer.GetInscription(id); err == nil {
+		t.Fatal("error code accepted")
+	}
+	if _, err := indexer.GetAddressInscriptions("bc1q"); err == nil {
+		t.Fatal("server error accepted")
+	}
+}
+
+func Test_UnisatIndexer(t *testing.T) {
+	server := newIndexerServer(t, map[string]func(r *http.Request) (int, string){
+		"/address/bc1q/brc20/ordi/info": func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":-1,"msg":"brc20 not found","data":null}`
+		},
+		"/address/bc1q/brc20/ordi/transferable-inscriptions": func(r *http.Request) (int, string) {
+			start := queryInt(r, "start")
+			if start >= 2 {
+				return http.StatusOK, `{"code":0,"data":{"total":2,"detail":[]}}`
+			}
+			return http.StatusOK, fmt.Sprintf(`{"code":0,"data":{"total":2,"detail":[{"data":{"op":"transfer","tick":"ordi","amt":"5"},"inscriptionId":"%si%d","confirmations":%d}]}}`, testInscriptionId[:64], start, start)
+		},
+		"/brc20/ordi/info": func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":-1,"msg":"api key invalid"}`
+		},
+		"/inscription/info/" + testInscriptionId: func(r *http.Request) (int, string) {
+			return http.StatusOK, `{"code":0,"data":{"inscriptionId":"` + testInscriptionId + `","address":"bc1q","location":"` + testInscriptionId[:64] + `:0:0","outputValue":546,"contentType":"text/plain"}}`
+		},
+		"/address/bc1q/inscription-data": func(r *http.Request) (int, string) {
+			cursor := queryInt(r, "cursor")
+			return http.StatusOK, fmt.Sprintf(`{"code":0,"data":{"total":3,"inscription":[{"inscriptionId":"%si%d","outputValue":546}]}}`, testInscriptionId[:64], cursor)
+		},
+	})
+	indexer := &unisatIndexer{api: server.URL}
+
+	balance, err := indexer.GetBalance("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.OverallBalance != "0" {
+		t.Fatalf("balance of an address without the ticker %+v", balance)
+	}
+	inscriptions, err := indexer.GetTransferableInscriptions("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inscriptions) != 2 || inscriptions[1].Confirmations != 1 {
+		t.Fatalf("%d transferable inscriptions over two pages", len(inscriptions))
+	}
+	if _, err := indexer.GetTickerInfo("ordi"); err == nil {
+		t.Fatal("error code accepted")
+	}
+	id, err := ordinal.ParseInscriptionID(testInscriptionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := indexer.GetInscription(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.OutputValue != 546 || info.ContentType != "text/plain" || info.Location != testInscriptionId[:64]+":0:0" {
+		t.Fatalf("inscription %+v", info)
+	}
+	all, err := indexer.GetAddressInscriptions("bc1q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[2].InscriptionId != testInscriptionId[:64]+"i2" {
+		t.Fatalf("%d address inscriptions over three pages", len(all))
+	}
+}
+
+func Test_OkxIndexer(t *testing.T) {
+	server := newIndexerServer(t, map[string]func(r *http.Request) (int, string){
+		"/address-balance-details": func(r *http.Request) (int, string) {
+			page := queryInt(r, "page")
+			return http.StatusOK, fmt.Sprintf(`{"code":"0","data":[{"page":"%d","totalPage":"2","token":"ordi","balance":"10","availableBalance":"6","transferBalance":"4","transferBalanceList":[{"inscriptionId":"%si%d","amount":"2"}]}]}`, page, testInscriptionId[:64], page)
+		},
+		"/token-details": func(r *http.Request) (int, string) {
+			if r.URL.Query().Get("token") != "ordi" {
+				return http.StatusOK, `{"code":"0","data":[]}`
+			}
+			return http.StatusOK, `{"code":"0","data":[{"token":"ordi","inscriptionId":"` + testInscriptionId + `","totalSupply":"21000000","mintAmount":"21000000","limitPerMint":"1000","precision":"18"}]}`
+		},
+		"/inscriptions-list": func(r *http.Request) (int, string) {
+			if r.URL.Query().Get("inscriptionId") != "" {
+				return http.StatusOK, `{"code":"50011","msg":"rate limited"}`
+			}
+			return http.StatusOK, `{"code":"0","data":[{"totalPage":"1","inscriptionsList":[{"inscriptionId":"` + testInscriptionId + `","location":"` + testInscriptionId[:64] + `:0:0","ownerAddress":"bc1q"}]}]}`
+		},
+	})
+	indexer := &okxIndexer{api: server.URL}
+
+	balance, err := indexer.GetBalance("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.TransferBalance != "4" || balance.AvailableBalance != "6" {
+		t.Fatalf("balance %+v", balance)
+	}
+	inscriptions, err := indexer.GetTransferableInscriptions("bc1q", "ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inscriptions) != 2 || inscriptions[1].InscriptionId != testInscriptionId[:64]+"i2" {
+		t.Fatalf("%d transferable inscriptions over two pages", len(inscriptions))
+	}
+	info, err := indexer.GetTickerInfo("ordi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Limit != "1000" || info.Decimals != 18 {
+		t.Fatalf("ticker info %+v", info)
+	}
+	if info, err := indexer.GetTickerInfo("none"); err != nil || info != nil {
+		t.Fatalf("unknown ticker: %+v, %v", info, err)
+	}
+	id, err := ordinal.ParseInscriptionID(testInscriptionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := indexer.GetInscription(id); err == nil {
+		t.Fatal("error code accepted")
+	}
+	all, err := indexer.GetAddressInscriptions("bc1q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Address != "bc1q" || all[0].OutputValue != 0 {
+		t.Fatalf("address inscriptions %+v", all[0])
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// maxStandardTxWeight is the heaviest transaction nodes relay.
+	maxStandardTxWeight = 400000
+	// maxChunkSize is the largest push a tapscript may execute.
+	maxChunkSize = txscript.MaxScriptElementSize
+)
+
+// inscriptionScript is the tap leaf revealing an ord envelope of contentType
+// and body, spendable by key. The body is pushed in chunks of maxChunkSize, and
+// the script is assembled by hand since tapscripts are not bound by the
+// 10000 byte limit the script builder enforces.
+func inscriptionScript(key *btcec.PublicKey, contentType string, body []byte) []byte {
+	script := pushData(nil, schnorr.SerializePubKey(key))
+	script = append(script, txscript.OP_CHECKSIG, txscript.OP_FALSE, txscript.OP_IF)
+	script = pushData(script, []byte("ord"))
+	// tag 1, the content type
+	script = append(script, txscript.OP_DATA_1, txscript.OP_DATA_1)
+	script = pushData(script, []byte(contentType))
+	// tag 0, the body
+	script = append(script, txscript.OP_0)
+	for i := 0; i < len(body); i += maxChunkSize {
+		end := min(i+maxChunkSize, len(body))
+		script = pushData(script, body[i:end])
+	}
+	return append(script, txscript.OP_ENDIF)
+}
+
+// pushData appends a push of data to script with the shortest push opcode
+// that takes data as is.
+func pushData(script []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n < txscript.OP_PUSHDATA1:
+		script = append(script, byte(n))
+	case n <= 0xff:
+		script = append(script, txscript.OP_PUSHDATA1, byte(n))
+	default:
+		script = append(script, txscript.OP_PUSHDATA2, byte(n), byte(n>>8))
+	}
+	return append(script, data...)
+}
+
+// inscriptionAddress is the P2TR address of key committing to script as its only leaf.
+func inscriptionAddress(key *btcec.PublicKey, script []byte, net *chaincfg.Params) (string, error) {
+	return tapLeafAddress(key, txscript.NewBaseTapLeaf(script).TapHash(), net)
+}
+
+// tapLeafAddress is the P2TR address of key committing to the leaf hash root as
+// its only leaf.
+func tapLeafAddress(key *btcec.PublicKey, root chainhash.Hash, net *chaincfg.Params) (string, error) {
+	outputKey := txscript.ComputeTaprootOutputKey(key, root[:])
+	address, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), net)
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+// revealTx spends output vout of commitTx through script, signed by key, to a
+// postage output to the address to, the rest of the output paying the fee.
+func revealTx(commitTx *wire.MsgTx, vout uint32, key *btcec.PrivateKey, script []byte, to string, postage int64, net *chaincfg.Params) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(2)
+	hash := commitTx.TxHash()
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, vout), nil, nil))
+	addr, err := decodeAddress(to, net)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(wire.NewTxOut(postage, pkScript))
+	prevOut := commitTx.TxOut[vout]
+	fetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+	leaf := txscript.NewBaseTapLeaf(script)
+	signature, err := txscript.RawTxInTapscriptSignature(tx, txscript.NewTxSigHashes(tx, fetcher), 0, prevOut.Value, prevOut.PkScript, leaf, txscript.SigHashDefault, key)
+	if err != nil {
+		return nil, err
+	}
+	tree := txscript.AssembleTaprootScriptTree(leaf)
+	controlBlock := tree.LeafMerkleProofs[0].ToControlBlock(key.PubKey())
+	controlBlockBytes, err := controlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].Witness = wire.TxWitness{signature, script, controlBlockBytes}
+	return tx, nil
+}
+
+// checkRevealWeight refuses an inscription script whose reveal nodes would not relay.
+func checkRevealWeight(script []byte) error {
+	size := &txsize.Estimator{}
+	size.AddTaprootScriptInput([]int{txsize.SchnorrSigLen}, len(script), 0)
+	// a P2TR output, the largest the reveal pays
+	size.AddOutput(make([]byte, 34))
+	if weight := size.Weight(); weight > maxStandardTxWeight {
+		return fmt.Errorf("%d byte inscription script makes a reveal of %d weight units, nodes relay at most %d", len(script), weight, maxStandardTxWeight)
+	}
+	return nil
+}
+
+// detectContentType returns the content type of the file at path, by its
+// extension or else by sniffing body.
+func detectContentType(path string, body []byte) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return http.DetectContentType(body)
+}
+
+func inscribeFile(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	contents := make([]*inscriptionContent, 0)
+	for _, path := range cli.StringSlice("file") {
+		body, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		contentType := cli.String("content-type")
+		if contentType == "" {
+			contentType = detectContentType(path, body)
+		}
+		fmt.Printf("%s: %s, %d bytes\n", path, contentType, len(body))
+		contents = append(contents, &inscriptionContent{contentType, body})
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("to: %v\n", to)
+	from, wif, err := getPayer(cli, policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	journal, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	inscriptionIds, err := inscribeBatch(from, wif, to, contents, cli.Int("postage"), feerate, source, journal, backend, net)
+	for _, inscriptionId := range inscriptionIds {
+		fmt.Println("inscriptionId: ", inscriptionId)
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/localindex"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin/brc20"
+)
+
+// Test_InscriptionScript checks the envelope matches the one the sdk builds,
+// so commit outputs of earlier versions are still found by their keys.
+func Test_InscriptionScript(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := bytes.Repeat([]byte("ord"), 400)
+	expected, err := brc20.CreateInscriptionScript(key, "text/plain;charset=utf-8", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := inscriptionScript(key.PubKey(), "text/plain;charset=utf-8", body)
+	if !bytes.Equal(script, expected) {
+		t.Fatalf("script %x, expected %x", script, expected)
+	}
+	expectedAddress, err := brc20.NewTapRootAddressWithScript(key, script, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := inscriptionAddress(key.PubKey(), script, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address != expectedAddress {
+		t.Fatalf("address %s, expected %s", address, expectedAddress)
+	}
+}
+
+func Test_InscribeFile(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, from, 1000000)
+	source := &coinSource{strategy: coinselect.Select}
+
+	// past the 10000 byte limit of the script builder
+	body := bytes.Repeat([]byte{0x89, 'P', 'N', 'G'}, 8000)
+	inscriptionId, err := inscribe(from, wifs[0], from, "image/png", body, 546, 2, source, newTestJournal(t), backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reveal := backend.posted[len(backend.posted)-1]
+	if reveal.TxHash().String() != inscriptionId[:64] {
+		t.Fatalf("last posted %s is not the reveal of %s", reveal.TxHash(), inscriptionId)
+	}
+	verifyTx(t, backend, reveal)
+	checkFeeRate(t, backend, reveal, 2)
+	envelopes := localindex.ParseEnvelopes(reveal)
+	if len(envelopes) != 1 || envelopes[0].ContentType != "image/png" || !bytes.Equal(envelopes[0].Body, body) {
+		t.Fatal("reveal does not carry the file")
+	}
+
+	posted := len(backend.posted)
+	_, err = inscribe(from, wifs[0], from, "image/png", make([]byte, 400000), 546, 2, source, newTestJournal(t), backend, net)
+	if err == nil || !strings.Contains(err.Error(), "weight units") {
+		t.Fatalf("expected a nonstandard reveal to be refused, got %v", err)
+	}
+	if len(backend.posted) != posted {
+		t.Fatal("nonstandard inscription broadcast")
+	}
+}
+
+func Test_DetectContentType(t *testing.T) {
+	for _, c := range []struct {
+		path     string
+		body     []byte
+		expected string
+	}{
+		{"meta.json", []byte(`{"name":"ord"}`), "application/json"},
+		{"index.html", []byte("<html></html>"), "text/html; charset=utf-8"},
+		{"image", []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{"note", []byte("hello"), "text/plain; charset=utf-8"},
+	} {
+		if contentType := detectContentType(c.path, c.body); contentType != c.expected {
+			t.Errorf("detectContentType(%q) = %q, expected %q", c.path, contentType, c.expected)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// the states of a journal entry
+const (
+	// journalPrepared entries have their commit and reveal signed but not broadcast.
+	journalPrepared = "prepared"
+	// journalCommitted entries have their commit broadcast.
+	journalCommitted = "committed"
+	// journalRevealed entries have their reveal broadcast, the inscription is done.
+	journalRevealed = "revealed"
+	// journalSwept entries had their commit output spent back to the funder instead.
+	journalSwept = "swept"
+)
+
+// journalEntry is an inscription recorded before anything of it is broadcast,
+// with what it takes to finish it or take the commit back.
+type journalEntry struct {
+	// CommitKey is the WIF of the key the commit output is locked to.
+	CommitKey string `json:"commitKey"`
+	// CommitIndex is the derivation index of CommitKey under the funder's key.
+	CommitIndex uint32 `json:"commitIndex"`
+	// CommitVout is the commit output the reveal spends.
+	CommitVout uint32 `json:"commitVout"`
+	// Script is the hex inscription script, the only tap leaf of the commit output.
+	Script   string    `json:"script"`
+	CommitTx string    `json:"commitTx"`
+	RevealTx string    `json:"revealTx"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	State    string    `json:"state"`
+	Updated  time.Time `json:"updated"`
+}
+
+// journal is the file of inscriptions in flight, keyed by commit outpoint.
+type journal struct {
+	path string
+	// dryRun journals are never saved
+	dryRun bool
+	// NextCommitIndex is the derivation index of the next commit key, it only
+	// ever goes up.
+	NextCommitIndex uint32 `json:"nextCommitIndex"`
+	// CommitLeaves is the hex tap leaf hash committed to under each commit key
+	// index handed out, kept for recover-commits once the entries are gone.
+	CommitLeaves map[uint32]string        `json:"commitLeaves"`
+	Entries      map[string]*journalEntry `json:"entries"`
+}
+
+func openJournal(path string) (*journal, error) {
+	j := &journal{path: path, CommitLeaves: make(map[uint32]string), Entries: make(map[string]*journalEntry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, j); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if j.CommitLeaves == nil {
+		j.CommitLeaves = make(map[uint32]string)
+	}
+	return j, nil
+}
+
+// save writes the journal to its file, replacing it atomically.
+func (j *journal) save() error {
+	if j.dryRun {
+		return nil
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// record stores entry under id in the state and saves the journal.
+func (j *journal) record(id string, entry *journalEntry, state string) error {
+	entry.State = state
+	entry.Updated = time.Now().UTC()
+	j.Entries[id] = entry
+	return j.save()
+}
+
+// nextCommitIndex reserves a commit key derivation index past every one
+// handed out, saved with the next record.
+func (j *journal) nextCommitIndex() uint32 {
+	index := j.NextCommitIndex
+	for used := range j.CommitLeaves {
+		if used >= index {
+			index = used + 1
+		}
+	}
+	j.NextCommitIndex = index + 1
+	return index
+}
+
+// commitLeaf records the tap leaf hash of script as committed to under the
+// commit key index, saved with the next record.
+func (j *journal) commitLeaf(index uint32, script []byte) {
+	leaf := txscript.NewBaseTapLeaf(script).TapHash()
+	j.CommitLeaves[index] = hex.EncodeToString(leaf[:])
+}
+
+// commitLeafHashes decodes CommitLeaves.
+func (j *journal) commitLeafHashes() (map[uint32]chainhash.Hash, error) {
+	leaves := make(map[uint32]chainhash.Hash, len(j.CommitLeaves))
+	for index, leaf := range j.CommitLeaves {
+		data, err := hex.DecodeString(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("commit leaf %d: %w", index, err)
+		}
+		hash, err := chainhash.NewHash(data)
+		if err != nil {
+			return nil, fmt.Errorf("commit leaf %d: %w", index, err)
+		}
+		leaves[index] = *hash
+	}
+	return leaves, nil
+}
+
+// pending returns the commit txids of the entries not revealed or swept, oldest first.
+func (j *journal) pending() []string {
+	ids := make([]string, 0)
+	for id, entry := range j.Entries {
+		if entry.State == journalPrepared || entry.State == journalCommitted {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(a, b int) bool {
+		return j.Entries[ids[a]].Updated.Before(j.Entries[ids[b]].Updated)
+	})
+	return ids
+}
+
+func getJournal(cmd *cli.Command) (*journal, error) {
+	j, err := openJournal(cmd.String("journal"))
+	if err != nil {
+		return nil, err
+	}
+	j.dryRun = cmd.Bool("dry-run")
+	return j, nil
+}
+
+func txHex(tx *wire.MsgTx) (string, error) {
+	var buffer bytes.Buffer
+	if err := tx.Serialize(&buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer.Bytes()), nil
+}
+
+func decodeTxHex(raw string) (*wire.MsgTx, error) {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	tx := &wire.MsgTx{}
+	return tx, tx.Deserialize(bytes.NewReader(data))
+}
+
+// broadcastOnce posts tx unless backend already knows it.
+func broadcastOnce(backend ChainBackend, tx *wire.MsgTx, net *chaincfg.Params) error {
+	known, err := hasUnspentOutput(backend, tx, net)
+	if err != nil {
+		return err
+	}
+	if known {
+		return nil
+	}
+	if _, err := postTransaction(backend, tx); err != nil {
+		// the post fails for a tx already in the mempool or with every output
+		// spent, which the raw lookup still finds on backends indexing txs
+		if _, lookupErr := backend.GetRawTransaction(tx.TxHash().String()); lookupErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// hasUnspentOutput reports whether an output of tx is among the utxos of its
+// address, which tells tx is known without a transaction index.
+func hasUnspentOutput(backend ChainBackend, tx *wire.MsgTx, net *chaincfg.Params) (bool, error) {
+	txid := tx.TxHash().String()
+	for vout, txOut := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(txOut.PkScript, net)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+		utxos, err := backend.GetUnspentUtxo(addrs[0].EncodeAddress())
+		if err != nil {
+			return false, err
+		}
+		for _, utxo := range utxos {
+			if utxo.Txid == txid && utxo.Vout == vout {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// resumeInscription finishes the inscription of the entry with id,
+// broadcasting its commit and reveal where backend does not know them yet.
+func resumeInscription(j *journal, id string, backend ChainBackend, net *chaincfg.Params) error {
+	entry := j.Entries[id]
+	commitTx, err := decodeTxHex(entry.CommitTx)
+	if err != nil {
+		return err
+	}
+	revealTx, err := decodeTxHex(entry.RevealTx)
+	if err != nil {
+		return err
+	}
+	revealed, err := hasUnspentOutput(backend, revealTx, net)
+	if err != nil {
+		return err
+	}
+	if revealed {
+		return j.record(id, entry, journalRevealed)
+	}
+	if err := broadcastOnce(backend, commitTx, net); err != nil {
+		return fmt.Errorf("commit %s: %w", id, err)
+	}
+	if err := j.record(id, entry, journalCommitted); err != nil {
+		return err
+	}
+	if err := broadcastOnce(backend, revealTx, net); err != nil {
+		return fmt.Errorf("reveal %s: %w", revealTx.TxHash(), err)
+	}
+	return j.record(id, entry, journalRevealed)
+}
+
+// sweepCommit spends the commit output of the entry with id back to
+// its funder through the key path, giving up on the reveal.
+func sweepCommit(j *journal, id string, feerate int64, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	entry := j.Entries[id]
+	commitTx, err := decodeTxHex(entry.CommitTx)
+	if err != nil {
+		return nil, err
+	}
+	if err := broadcastOnce(backend, commitTx, net); err != nil {
+		return nil, fmt.Errorf("commit %s: %w", id, err)
+	}
+	wif, err := btcutil.DecodeWIF(entry.CommitKey)
+	if err != nil {
+		return nil, err
+	}
+	script, err := hex.DecodeString(entry.Script)
+	if err != nil {
+		return nil, err
+	}
+	hash := commitTx.TxHash()
+	root := txscript.NewBaseTapLeaf(script).TapHash()
+	tx, err := sweepCommitOutput(wif.PrivKey, root, wire.NewOutPoint(&hash, entry.CommitVout), commitTx.TxOut[entry.CommitVout], entry.From, feerate, backend, net)
+	if err != nil {
+		return nil, err
+	}
+	return tx, j.record(id, entry, journalSwept)
+}
+
+// sweepCommitOutput spends the output at outpoint, locked to key with the tap
+// leaf hash root as its script tree, through the key path to the address to.
+func sweepCommitOutput(key *btcec.PrivateKey, root chainhash.Hash, outpoint *wire.OutPoint, prevOut *wire.TxOut, to string, feerate int64, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	decodedToAddr, err := decodeAddress(to, net)
+	if err != nil {
+		return nil, err
+	}
+	toAddrByte, err := txscript.PayToAddrScript(decodedToAddr)
+	if err != nil {
+		return nil, err
+	}
+	size := &txsize.Estimator{}
+	size.AddTaprootKeyInput()
+	size.AddOutput(toAddrByte)
+	value := prevOut.Value - size.Fee(feerate)
+	if value < minChange {
+		return nil, fmt.Errorf("output %v of %d does not cover the sweep fee", outpoint, prevOut.Value)
+	}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(outpoint, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(value, toAddrByte))
+	fetcher := txscript.NewCannedPrevOutputFetcher(prevOut.PkScript, prevOut.Value)
+	signature, err := txscript.RawTxInTaprootSignature(tx, txscript.NewTxSigHashes(tx, fetcher), 0, prevOut.Value, prevOut.PkScript, root[:], txscript.SigHashDefault, key)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[0].Witness = wire.TxWitness{signature}
+	if _, err := postTransaction(backend, tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func resume(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	j, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	ids := j.pending()
+	if cli.Args().Present() {
+		ids = cli.Args().Slice()
+	}
+	var feerate int64
+	if cli.Bool("sweep") {
+		feerate, err = getFeeRate(cli, backend)
+		if err != nil {
+			return err
+		}
+	}
+	failed := 0
+	for _, id := range ids {
+		if err := resumeEntry(j, id, cli.Bool("sweep"), feerate, backend, net); err != nil {
+			fmt.Printf("%s: %v\n", id, err)
+			failed++
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println("no pending inscriptions")
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d inscriptions failed to resume", failed, len(ids))
+	}
+	return nil
+}
+
+// resumeEntry reveals or, with sweep, sweeps back the entry with id.
+func resumeEntry(j *journal, id string, sweep bool, feerate int64, backend ChainBackend, net *chaincfg.Params) error {
+	entry, ok := j.Entries[id]
+	if !ok {
+		return fmt.Errorf("no inscription with commit %s in %s", id, j.path)
+	}
+	if sweep {
+		tx, err := sweepCommit(j, id, feerate, backend, net)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("swept %s to %s: %s\n", id, entry.From, tx.TxHash())
+		return nil
+	}
+	if err := resumeInscription(j, id, backend, net); err != nil {
+		return err
+	}
+	revealTx, err := decodeTxHex(entry.RevealTx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("inscriptionId: %si0\n", revealTx.TxHash())
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+func newTestJournal(t *testing.T) *journal {
+	t.Helper()
+	j, err := openJournal(filepath.Join(t.TempDir(), "journal.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+// flakyBackend accepts posts until it has taken its quota.
+type flakyBackend struct {
+	*memoryBackend
+	quota int
+}
+
+func (b *flakyBackend) PostTransaction(raw string) (string, error) {
+	if b.quota == 0 {
+		return "", fmt.Errorf("connection reset")
+	}
+	b.quota--
+	return b.memoryBackend.PostTransaction(raw)
+}
+
+// noTxIndexBackend looks up no transactions, like bitcoind without txindex.
+type noTxIndexBackend struct {
+	*memoryBackend
+}
+
+func (b *noTxIndexBackend) GetRawTransaction(txid string) (string, error) {
+	return "", fmt.Errorf("no such mempool or blockchain transaction")
+}
+
+// Test_ResumeInscription breaks the connection after the commit and finishes
+// the inscription from a reopened journal, by revealing it or sweeping it back.
+func Test_ResumeInscription(t *testing.T) {
+	for _, sweep := range []bool{false, true} {
+		t.Run(fmt.Sprintf("sweep=%v", sweep), func(t *testing.T) {
+			net := &chaincfg.RegressionNetParams
+			backend := newMemoryBackend(net)
+			wifs := newTestWIFs(t, net, 1)
+			from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend.fund(t, from, 80000)
+			j := newTestJournal(t)
+			source := &coinSource{strategy: coinselect.Select}
+			flaky := &flakyBackend{memoryBackend: backend, quota: 1}
+			if _, err := inscribe(from, wifs[0], from, "text/plain", []byte("ord"), 546, 2, source, j, flaky, net); err == nil {
+				t.Fatal("inscribed with the reveal lost")
+			}
+
+			reopened, err := openJournal(j.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pending := reopened.pending()
+			if len(pending) != 1 || reopened.Entries[pending[0]].State != journalCommitted {
+				t.Fatalf("pending entries: %v", pending)
+			}
+			id := pending[0]
+
+			if sweep {
+				tx, err := sweepCommit(reopened, id, 2, backend, net)
+				if err != nil {
+					t.Fatal(err)
+				}
+				verifyTx(t, backend, tx)
+				checkFeeRate(t, backend, tx, 2)
+			} else {
+				if err := resumeInscription(reopened, id, backend, net); err != nil {
+					t.Fatal(err)
+				}
+				revealTx := backend.posted[len(backend.posted)-1]
+				if revealTx.TxIn[0].PreviousOutPoint.String() != id {
+					t.Fatalf("reveal spends %v", revealTx.TxIn[0].PreviousOutPoint)
+				}
+				verifyTx(t, backend, revealTx)
+				// a second resume finds the reveal without a transaction index
+				posted := len(backend.posted)
+				if err := resumeInscription(reopened, id, &noTxIndexBackend{backend}, net); err != nil {
+					t.Fatal(err)
+				}
+				if len(backend.posted) != posted {
+					t.Fatal("resume broadcast the inscription again")
+				}
+			}
+			if len(reopened.pending()) != 0 {
+				t.Fatal("entry still pending")
+			}
+		})
+	}
+}
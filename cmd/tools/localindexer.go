@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+
+	"brc20tools/localindex"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// localIndexer is a BRC20Indexer on the store built by the index command.
+type localIndexer struct {
+	store *localindex.Store
+}
+
+func openLocalIndexer(cmd *cli.Command, net *chaincfg.Params) (*localIndexer, error) {
+	store, err := localindex.OpenStore(cmd.String("local-index"), localindex.StartHeights[net.Name])
+	if err != nil {
+		return nil, err
+	}
+	return &localIndexer{store: store}, nil
+}
+
+// IndexedHeight is the last block the index command indexed.
+func (l *localIndexer) IndexedHeight() (int64, error) {
+	return l.store.Height, nil
+}
+
+func (l *localIndexer) GetBalance(address string, ticker string) (*brc20Balance, error) {
+	balance, ok := l.store.Balances[address][strings.ToLower(ticker)]
+	if !ok {
+		return zeroBalance(ticker), nil
+	}
+	overall := new(big.Int).Add(balance.Available, balance.Transferable)
+	return &brc20Balance{
+		Ticker:           ticker,
+		OverallBalance:   l.store.FormatAmount(ticker, overall),
+		TransferBalance:  l.store.FormatAmount(ticker, balance.Transferable),
+		AvailableBalance: l.store.FormatAmount(ticker, balance.Available),
+	}, nil
+}
+
+func (l *localIndexer) GetTransferableInscriptions(address string, ticker string) ([]*transferableInscription, error) {
+	inscriptions := make([]*transferableInscription, 0)
+	for _, transfer := range l.store.TransferableInscriptions(address, ticker) {
+		inscriptions = append(inscriptions, &transferableInscription{
+			InscriptionId: transfer.InscriptionID,
+			Ticker:        transfer.Tick,
+			Amount:        l.store.FormatAmount(transfer.Tick, transfer.Amount),
+			Confirmations: int(l.store.Height - transfer.Height + 1),
+		})
+	}
+	return inscriptions, nil
+}
+
+func (l *localIndexer) GetTickerInfo(ticker string) (*tickerInfo, error) {
+	info := l.store.Ticker(ticker)
+	if info == nil {
+		return nil, nil
+	}
+	return &tickerInfo{
+		Ticker:        info.Tick,
+		InscriptionId: info.InscriptionID,
+		Max:           l.store.FormatAmount(info.Tick, info.Max),
+		Limit:         l.store.FormatAmount(info.Tick, info.Limit),
+		Minted:        l.store.FormatAmount(info.Tick, info.Minted),
+		Decimals:      info.Decimals,
+	}, nil
+}
+
+func (l *localIndexer) GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error) {
+	inscription := l.store.Inscription(inscriptionId.String())
+	if inscription == nil {
+		return nil, fmt.Errorf("inscription not indexed: %v", inscriptionId)
+	}
+	return &inscriptionInfo{
+		InscriptionId: inscription.ID,
+		Address:       inscription.Address,
+		Location:      inscription.Location(),
+		OutputValue:   inscription.Value,
+		ContentType:   inscription.ContentType,
+	}, nil
+}
+
+func (l *localIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	inscriptions := make([]*inscriptionInfo, 0)
+	for _, inscription := range l.store.Inscriptions {
+		if inscription.Address != address || inscription.Outpoint == "" {
+			continue
+		}
+		inscriptions = append(inscriptions, &inscriptionInfo{
+			InscriptionId: inscription.ID,
+			Address:       inscription.Address,
+			Location:      inscription.Location(),
+			OutputValue:   inscription.Value,
+			ContentType:   inscription.ContentType,
+		})
+	}
+	return inscriptions, nil
+}
+
+// chainBlockSource reads blocks for the local indexer from a ChainBackend.
+type chainBlockSource struct {
+	backend ChainBackend
+}
+
+func (c *chainBlockSource) TipHeight() (int64, error) {
+	return c.backend.GetBlockHeight()
+}
+
+func (c *chainBlockSource) Block(height int64) (*wire.MsgBlock, error) {
+	raw, err := c.backend.GetRawBlock(height)
+	if err != nil {
+		return nil, err
+	}
+	block := &wire.MsgBlock{}
+	err = block.Deserialize(bytes.NewReader(raw))
+	return block, err
+}
+
+// chainPrevouts fetches the outputs spent before the indexed range from backend.
+func chainPrevouts(backend ChainBackend) localindex.PrevoutFetcher {
+	return func(outpoint wire.OutPoint) (*wire.TxOut, error) {
+		tx, err := getTransction(backend, outpoint.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		if int(outpoint.Index) >= len(tx.TxOut) {
+			return nil, fmt.Errorf("no output %v", outpoint)
+		}
+		return tx.TxOut[outpoint.Index], nil
+	}
+}
+
+func indexBlocks(ctx context.Context, cmd *cli.Command) error {
+	net, err := getNetwork(cmd)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cmd, net)
+	if err != nil {
+		return err
+	}
+	startHeight := localindex.StartHeights[net.Name]
+	if cmd.IsSet("start-height") {
+		startHeight = cmd.Int("start-height")
+	}
+	store, err := localindex.OpenStore(cmd.String("local-index"), startHeight)
+	if err != nil {
+		return err
+	}
+	var source localindex.BlockSource = &chainBlockSource{backend: backend}
+	if dir := cmd.String("blocks-dir"); dir != "" {
+		source, err = localindex.NewFileBlockSource(dir, net)
+		if err != nil {
+			return err
+		}
+	}
+	indexer := localindex.NewIndexer(store, net, chainPrevouts(backend))
+	log.Printf("indexing from block %d", store.Height+1)
+	err = indexer.Sync(source, 100, func(height int64) {
+		if height%100 == 0 {
+			log.Printf("indexed block %d", height)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("indexed to block %d, %d inscriptions", store.Height, len(store.Inscriptions))
+	return nil
+}
@@ -1,37 +1,123 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
-	"strings"
+
+	"brc20tools/ordinal"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/joho/godotenv"
-	"github.com/okx/go-wallet-sdk/coins/bitcoin"
 	"github.com/urfave/cli/v3"
 )
 
-var NET = &chaincfg.TestNet3Params
-
-const TICK = "qwpo"
-const AMOUNT = "1000"
-const BRC20AMOUNT = 546
-
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
 	cmd := &cli.Command{
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "network",
+				Value:   "testnet3",
+				Usage:   "bitcoin network: mainnet, testnet3, signet or regtest",
+				Sources: cli.EnvVars("NETWORK"),
+			},
+			&cli.StringFlag{
+				Name:    "backend",
+				Value:   "esplora",
+				Usage:   "chain backend: esplora, bitcoind or electrum",
+				Sources: cli.EnvVars("CHAIN_BACKEND"),
+			},
+			&cli.StringFlag{
+				Name:    "indexer",
+				Value:   "merlin",
+				Usage:   "brc20 indexer: merlin, unisat, okx or local",
+				Sources: cli.EnvVars("INDEXER"),
+			},
+			&cli.StringFlag{
+				Name:    "local-index",
+				Value:   "brc20index.json",
+				Usage:   "store file of the local indexer",
+				Sources: cli.EnvVars("LOCAL_INDEX"),
+			},
+			&cli.StringFlag{
+				Name:    "tick",
+				Value:   "qwpo",
+				Usage:   "brc20 ticker, 4 or 5 bytes",
+				Sources: cli.EnvVars("TICK"),
+			},
+			&cli.IntFlag{
+				Name:    "decimals",
+				Value:   maxDecimals,
+				Usage:   "decimal precision of the ticker",
+				Sources: cli.EnvVars("TICK_DECIMALS"),
+			},
+			&cli.IntFlag{
+				Name:    "postage",
+				Value:   546,
+				Usage:   "satoshis carried by an inscription output",
+				Sources: cli.EnvVars("POSTAGE"),
+			},
+			&cli.StringFlag{
+				Name:    "coin-selection",
+				Value:   "auto",
+				Usage:   "coin selection: auto, bnb, knapsack or largest-first",
+				Sources: cli.EnvVars("COIN_SELECTION"),
+			},
+			&cli.IntFlag{
+				Name:    "fee-rate",
+				Usage:   "fee rate in sat/vB, estimated from --priority when not set",
+				Sources: cli.EnvVars("FEE_RATE"),
+			},
+			&cli.StringFlag{
+				Name:    "priority",
+				Value:   "halfhour",
+				Usage:   "fee estimate preset: fastest, halfhour, hour or economy",
+				Sources: cli.EnvVars("FEE_PRIORITY"),
+			},
+			&cli.IntFlag{
+				Name:    "min-fee-rate",
+				Value:   1,
+				Usage:   "floor of the fee rate in sat/vB",
+				Sources: cli.EnvVars("MIN_FEE_RATE"),
+			},
+			&cli.IntFlag{
+				Name:    "max-fee-rate",
+				Value:   50,
+				Usage:   "ceiling of the fee rate in sat/vB",
+				Sources: cli.EnvVars("MAX_FEE_RATE"),
+			},
+			&cli.IntFlag{
+				Name:    "payer",
+				Value:   1,
+				Usage:   "index of the cosigner paying fees and inscribing",
+				Sources: cli.EnvVars("FEE_PAYER"),
+			},
+			&cli.BoolFlag{
+				Name:    "spend-ordinals",
+				Usage:   "let utxos carrying inscriptions pay fees, burning their inscriptions",
+				Sources: cli.EnvVars("SPEND_ORDINALS"),
+			},
+			&cli.BoolFlag{
+				Name:    "dry-run",
+				Usage:   "build and sign transactions and print them instead of broadcasting",
+				Sources: cli.EnvVars("DRY_RUN"),
+			},
+			&cli.StringFlag{
+				Name:    "journal",
+				Value:   "inscriptions.journal.json",
+				Usage:   "file recording inscriptions before they are broadcast",
+				Sources: cli.EnvVars("JOURNAL"),
+			},
+		},
 		Commands: []*cli.Command{
 			{
 				Name:    "keys",
@@ -54,14 +140,66 @@ func main() {
 			{
 				Name:    "mint",
 				Aliases: []string{"m"},
-				Usage:   fmt.Sprintf("mint %s to address", TICK),
+				Usage:   "mint brc20 to address",
 				Action:  mint,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "amount",
+						Value:   "1000",
+						Usage:   "amount to mint",
+						Sources: cli.EnvVars("MINT_AMOUNT"),
+					},
+					&cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "number of inscriptions to batch behind one commit, at most 24",
+					},
+				},
+			},
+			{
+				Name:   "deploy",
+				Usage:  "deploy the brc20 ticker, inscribed to address",
+				Action: deploy,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "max",
+						Required: true,
+						Usage:    "max supply, 0 for an unlimited self-mint ticker",
+					},
+					&cli.StringFlag{
+						Name:  "lim",
+						Usage: "limit per mint, defaults to the max supply, required when max is 0",
+					},
+				},
+			},
+			{
+				Name:      "inscribe",
+				Usage:     "inscribe files to address",
+				ArgsUsage: "<address or cosigner index>",
+				Action:    inscribeFile,
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{Name: "file", Required: true, Usage: "file to inscribe, repeat to batch up to 24 behind one commit"},
+					&cli.StringFlag{Name: "content-type", Usage: "content type of the files, detected from each when not set"},
+				},
 			},
 			{
 				Name:    "inscribe-transfer",
 				Aliases: []string{"it"},
 				Usage:   "inscribe a transfer inscription to mutilsig address",
 				Action:  inscribeTransferFunc,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "amount",
+						Value:   "100",
+						Usage:   "amount to transfer",
+						Sources: cli.EnvVars("TRANSFER_AMOUNT"),
+					},
+					&cli.IntFlag{
+						Name:  "count",
+						Value: 1,
+						Usage: "number of inscriptions to batch behind one commit, at most 24",
+					},
+				},
 			},
 			{
 				Name:    "list-inscriptions",
@@ -70,10 +208,186 @@ func main() {
 				Action:  listInscriptions,
 			},
 			{
-				Name:    "send-inscription",
-				Aliases: []string{"s"},
-				Usage:   "send inscription from multisig to address",
-				Action:  sendInscription,
+				Name:   "index",
+				Usage:  "sync the local brc20 index from the chain backend or block files",
+				Action: indexBlocks,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "blocks-dir",
+						Usage:   "read blk*.dat files from this bitcoind blocks directory instead of the backend",
+						Sources: cli.EnvVars("BLOCKS_DIR"),
+					},
+					&cli.IntFlag{
+						Name:  "start-height",
+						Usage: "first block of a new index, defaults to the first inscription of the network",
+					},
+				},
+			},
+			{
+				Name:    "list-utxos",
+				Aliases: []string{"lu"},
+				Usage:   "list utxos of signers and multisig labeled cardinal or ordinal",
+				Action:  listUtxos,
+			},
+			{
+				Name:      "send-inscription",
+				Aliases:   []string{"s"},
+				Usage:     "send inscription from multisig or a signer to address",
+				ArgsUsage: "<to> <inscription id>",
+				Action:    sendInscription,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Value: "multisig",
+						Usage: "holder of the inscription: multisig, a signer index or name, or one of their addresses",
+					},
+				},
+			},
+			{
+				Name:   "send-btc",
+				Usage:  "send sats from multisig or a signer to addresses, leaving inscriptions alone",
+				Action: sendBtc,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Required: true, Usage: "sender: multisig, a signer index or name, or one of their addresses"},
+					&cli.StringSliceFlag{Name: "to", Required: true, Usage: "recipient address or signer index, repeat for several"},
+					&cli.StringSliceFlag{Name: "amount", Required: true, Usage: "sats for each recipient in order, or max to share what the sender has left"},
+					&cli.StringFlag{Name: "out", Value: "send-btc.psbt", Usage: "psbt file to write when spending from multisig"},
+				},
+			},
+			{
+				Name:   "sweep",
+				Usage:  "send every cardinal utxo of multisig or a signer to an address",
+				Action: sweep,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Required: true, Usage: "sender: multisig, a signer index or name, or one of their addresses"},
+					&cli.StringSliceFlag{Name: "to", Required: true, Usage: "recipient address or signer index, the last takes the rest"},
+					&cli.StringSliceFlag{Name: "amount", Usage: "sats for each recipient but the last, in order"},
+					&cli.StringFlag{Name: "out", Value: "sweep.psbt", Usage: "psbt file to write when spending from multisig"},
+				},
+			},
+			{
+				Name:  "utxo",
+				Usage: "reshape the cardinal utxos of multisig or a signer, leaving inscriptions alone",
+				Commands: []*cli.Command{
+					{
+						Name:   "consolidate",
+						Usage:  "merge the cardinal utxos into one while fees are low",
+						Action: utxoConsolidate,
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "from", Required: true, Usage: "owner: multisig, a signer index or name, or one of their addresses"},
+							&cli.IntFlag{Name: "below", Value: 5, Usage: "refuse above this fee rate in sat/vB"},
+							&cli.StringFlag{Name: "out", Value: "consolidate.psbt", Usage: "psbt file to write when spending from multisig"},
+						},
+					},
+					{
+						Name:   "split",
+						Usage:  "create evenly sized funding utxos for parallel mints",
+						Action: utxoSplit,
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "from", Required: true, Usage: "owner: multisig, a signer index or name, or one of their addresses"},
+							&cli.IntFlag{Name: "count", Required: true, Usage: "number of utxos to create"},
+							&cli.IntFlag{Name: "amount", Usage: "sats of each utxo, the whole balance split evenly when not set"},
+							&cli.StringFlag{Name: "out", Value: "split.psbt", Usage: "psbt file to write when spending from multisig"},
+						},
+					},
+				},
+			},
+			{
+				Name:  "psbt",
+				Usage: "send an inscription from multisig through BIP174 files signed by each cosigner, p2tr through its multi_a script leaf",
+				Commands: []*cli.Command{
+					{
+						Name:      "create",
+						Usage:     "write the unsigned transaction sending inscription from multisig to address",
+						ArgsUsage: "<to> <inscription id>",
+						Action:    psbtCreate,
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "out", Value: "send-inscription.psbt", Usage: "psbt file to write"},
+						},
+					},
+					{
+						Name:      "sign",
+						Usage:     "add the signatures of a cosigner",
+						ArgsUsage: "<psbt file>",
+						Action:    psbtSign,
+						Flags: []cli.Flag{
+							&cli.IntFlag{Name: "key", Required: true, Usage: "index of the signing cosigner"},
+							&cli.StringFlag{Name: "out", Usage: "psbt file to write, defaults to the input"},
+						},
+					},
+					{
+						Name:      "combine",
+						Usage:     "merge the signatures of copies of a psbt",
+						ArgsUsage: "<psbt file>...",
+						Action:    psbtCombine,
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "out", Required: true, Usage: "psbt file to write"},
+						},
+					},
+					{
+						Name:      "finalize",
+						Usage:     "build the final scripts and witnesses from the gathered signatures",
+						ArgsUsage: "<psbt file>",
+						Action:    psbtFinalize,
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "out", Usage: "psbt file to write, defaults to the input"},
+						},
+					},
+					{
+						Name:      "broadcast",
+						Usage:     "extract and broadcast a finalized psbt",
+						ArgsUsage: "<psbt file>",
+						Action:    psbtBroadcast,
+					},
+				},
+			},
+			{
+				Name:      "sign-offline",
+				Usage:     "review and sign a psbt with no network, for air-gapped cosigners",
+				ArgsUsage: "<psbt file>",
+				Action:    signOffline,
+				Flags: []cli.Flag{
+					&cli.IntFlag{Name: "key", Required: true, Usage: "index of the signing cosigner"},
+					&cli.StringFlag{Name: "out", Usage: "psbt file to write, defaults to the input"},
+					&cli.BoolFlag{Name: "yes", Usage: "sign without asking after the summary"},
+				},
+			},
+			{
+				Name:   "migrate",
+				Usage:  "move the utxos and inscriptions of the multisig from one script type to another, a p2tr source spent through the MuSig2 key path only when every cosigner key is local",
+				Action: migrate,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "from",
+						Value: multisigP2SH,
+						Usage: "script type to move from: p2sh, p2wsh, p2sh-p2wsh or p2tr",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Value: multisigP2WSH,
+						Usage: "script type to move to: p2sh, p2wsh, p2sh-p2wsh or p2tr",
+					},
+				},
+			},
+			{
+				Name:      "resume",
+				Usage:     "broadcast the unfinished inscriptions of the journal, or sweep their commits back",
+				ArgsUsage: "[commit outpoint...]",
+				Action:    resume,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "sweep", Usage: "spend the commit outputs back to their funder instead of revealing"},
+				},
+			},
+			{
+				Name:   "recover-commits",
+				Usage:  "scan the commit keys of the payer for unspent commit outputs, rebuilt from the leaf hashes in the journal or from --body, and sweep them to the payer",
+				Action: recoverCommits,
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "body", Usage: "content of an inscription missing from the journal"},
+					&cli.StringFlag{Name: "content-type", Value: "text/plain;charset=utf-8", Usage: "content type of the --body inscription"},
+					&cli.IntFlag{Name: "start", Usage: "first commit key index to scan"},
+					&cli.IntFlag{Name: "gap", Value: 20, Usage: "stop after this many commit key indexes in a row without a commit"},
+				},
 			},
 		},
 	}
@@ -83,78 +397,85 @@ func main() {
 	}
 }
 
-func getWIFs() ([]*btcutil.WIF, error) {
-	wif1, err := btcutil.DecodeWIF(os.Getenv("REDEEM_SERVICES"))
-	if err != nil {
-		return nil, err
-	}
-	wifs := make([]*btcutil.WIF, 0)
-	wifs = append(wifs, wif1)
-
-	wif2, err := btcutil.DecodeWIF(os.Getenv("TREASURY_SERVICES"))
-	if err != nil {
-		return nil, err
+// getTick returns the --tick flag checked against the brc20 rules.
+func getTick(cmd *cli.Command) (string, error) {
+	tick := cmd.String("tick")
+	if err := checkTick(tick); err != nil {
+		return "", err
 	}
-	wifs = append(wifs, wif2)
+	return tick, nil
+}
 
-	wif3, err := btcutil.DecodeWIF(os.Getenv("TREASURY_BACKUP"))
-	if err != nil {
-		return nil, err
+// getAmount returns the --amount flag checked against the --decimals of the ticker.
+func getAmount(cmd *cli.Command) (string, error) {
+	amount := cmd.String("amount")
+	if err := checkAmount(amount, cmd.Int("decimals")); err != nil {
+		return "", err
 	}
-	wifs = append(wifs, wif3)
-	return wifs, nil
+	return amount, nil
 }
 
-func getMultiAddress(wifs []*btcutil.WIF) (string, []byte, error) {
-	addressPubKeys := make([]*btcutil.AddressPubKey, 0)
-	for _, wif := range wifs {
-		addressPubKey, err := btcutil.NewAddressPubKey(wif.SerializePubKey(), NET)
+// getToAddress resolves a command's destination argument, either a cosigner index
+// (len(policy.cosigners) for the multisig) or an address on net.
+func getToAddress(arg string, policy *multisigPolicy, net *chaincfg.Params) (string, error) {
+	index, err := strconv.ParseInt(arg, 10, 10)
+	if err != nil {
+		addr, err := decodeAddress(arg, net)
 		if err != nil {
-			return "", nil, err
+			return "", err
 		}
-		addressPubKeys = append(addressPubKeys, addressPubKey)
+		return addr.EncodeAddress(), nil
 	}
-	const nRequired = 2
-	script, err := txscript.MultiSigScript(addressPubKeys, nRequired)
-	if err != nil {
-		return "", nil, err
+	if index < 0 || index > int64(len(policy.cosigners)) {
+		return "", fmt.Errorf("error to index: %s", arg)
 	}
-	// log.Printf("redeemScript: %s", hex.EncodeToString(script))
-	addr, err := btcutil.NewAddressScriptHashFromHash(btcutil.Hash160(script), NET)
-	if err != nil {
-		return "", nil, err
+	if index == int64(len(policy.cosigners)) {
+		to, _, err := policy.address(net)
+		return to, err
 	}
-	return addr.EncodeAddress(), script, nil
+	return policy.cosignerAddress(int(index), net)
 }
 
 func keys(ctx context.Context, cmd *cli.Command) error {
-	wifs, err := getWIFs()
+	net, err := getNetwork(cmd)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
 	if err != nil {
 		return err
 	}
 
-	for i, wif := range wifs {
-		address, err := bitcoin.PubKeyToAddr(wif.SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
+	for i, c := range policy.cosigners {
+		address, err := policy.cosignerAddress(i, net)
 		if err != nil {
 			return err
 		}
+		if c.wif == nil {
+			log.Printf("signer%d's address: %s (public key only)", i, address)
+			continue
+		}
 		log.Printf("signer%d's address: %s", i, address)
 	}
-	multiAddress, _, err := getMultiAddress(wifs)
+	multiAddress, _, err := policy.address(net)
 	if err != nil {
 		return err
 	}
-	log.Printf("multi address: %s", multiAddress)
+	log.Printf("multi address (%d-of-%d %s): %s", policy.threshold, len(policy.cosigners), policy.scriptType, multiAddress)
 	return nil
 }
 
-func newPrivateKey(context.Context, *cli.Command) error {
+func newPrivateKey(ctx context.Context, cmd *cli.Command) error {
+	net, err := getNetwork(cmd)
+	if err != nil {
+		return err
+	}
 	privkey, err := btcec.NewPrivateKey()
 	if err != nil {
 		return err
 	}
 
-	wif, err := btcutil.NewWIF(privkey, NET, true)
+	wif, err := btcutil.NewWIF(privkey, net, true)
 	if err != nil {
 		return err
 	}
@@ -162,101 +483,159 @@ func newPrivateKey(context.Context, *cli.Command) error {
 	return nil
 }
 
-func printBalance(context.Context, *cli.Command) error {
+func printBalance(ctx context.Context, cmd *cli.Command) error {
+	net, err := getNetwork(cmd)
+	if err != nil {
+		return err
+	}
+	tick, err := getTick(cmd)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cmd, net)
+	if err != nil {
+		return err
+	}
+	indexer, err := getIndexer(cmd, net)
+	if err != nil {
+		return err
+	}
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"#", "Address(SegWit)", "Satoshi", fmt.Sprintf("BRC20(%s) available", TICK), fmt.Sprintf("BRC20(%s) transfer", TICK)})
+	t.AppendHeader(table.Row{"#", "Address(SegWit)", "Satoshi", fmt.Sprintf("BRC20(%s) available", tick), fmt.Sprintf("BRC20(%s) transfer", tick)})
 	t.AppendSeparator()
-	wifs, err := getWIFs()
+	policy, err := getPolicy(net)
 	if err != nil {
 		return err
 	}
-	for i, wif := range wifs {
-		address, err := bitcoin.PubKeyToAddr(wif.SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-		if err != nil {
-			return err
-		}
-		getAddressResp, err := getAddress(address)
+	addresses, err := policy.addresses(net)
+	if err != nil {
+		return err
+	}
+	for i, address := range addresses {
+		satoshi, err := backend.GetBalance(address)
 		if err != nil {
 			return err
 		}
-		getAddressSummaryResp, err := getAddressSummary(address, os.Getenv("INDEXER_AUTH"))
+		balance, err := indexer.GetBalance(address, tick)
 		if err != nil {
 			return err
 		}
-		if getAddressSummaryResp.Code != 0 {
-			return fmt.Errorf("getAddressSummary code: %d", getAddressSummaryResp.Code)
-		}
-		tickerBalance := "0"
-		transferBalance := "0"
-		for _, item := range getAddressSummaryResp.Data.Items {
-			if strings.ToLower(item.Ticker) == TICK {
-				tickerBalance = item.AvailableBalance
-				transferBalance = item.TransferBalance
-			}
-		}
-		t.AppendRow([]interface{}{i, address, getAddressResp.ChainStats.FundedTxoSum, tickerBalance, transferBalance})
+		t.AppendRow([]interface{}{i, address, satoshi, balance.AvailableBalance, balance.TransferBalance})
 	}
-	multiAddress, _, err := getMultiAddress(wifs)
+	t.Render()
+	return nil
+}
+
+func mint(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
 	if err != nil {
 		return err
 	}
-	getAddressResp, err := getAddress(multiAddress)
+	backend, err := getChainBackend(cli, net)
 	if err != nil {
 		return err
 	}
-	getAddressSummaryResp, err := getAddressSummary(multiAddress, os.Getenv("INDEXER_AUTH"))
+	tick, err := getTick(cli)
 	if err != nil {
 		return err
 	}
-	if getAddressSummaryResp.Code != 0 {
-		return fmt.Errorf("getAddressSummary code: %d", getAddressSummaryResp.Code)
+	amount, err := getAmount(cli)
+	if err != nil {
+		return err
 	}
-	tickerBalance := "0"
-	transferBalance := "0"
-	for _, item := range getAddressSummaryResp.Data.Items {
-		if strings.ToLower(item.Ticker) == TICK {
-			tickerBalance = item.AvailableBalance
-			transferBalance = item.TransferBalance
-		}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("to: %v\n", to)
+	from, wif, err := getPayer(cli, policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	journal, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	inscriptionIds, err := brc20Mint(from, wif, to, tick, amount, int(cli.Int("count")), cli.Int("postage"), feerate, source, journal, backend, net)
+	for _, inscriptionId := range inscriptionIds {
+		fmt.Println("inscriptionId: ", inscriptionId)
+	}
+	if err != nil {
+		return err
 	}
-	t.AppendRow([]interface{}{len(wifs), multiAddress, getAddressResp.ChainStats.FundedTxoSum, tickerBalance, transferBalance})
-	t.Render()
 	return nil
 }
 
-func mint(ctx context.Context, cli *cli.Command) error {
-	toIndex := cli.Args().Get(0)
-	index, err := strconv.ParseInt(toIndex, 10, 10)
+func deploy(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
 	if err != nil {
 		return err
 	}
-	wifs, err := getWIFs()
+	backend, err := getChainBackend(cli, net)
 	if err != nil {
 		return err
 	}
-	if index < 0 || index > int64(len(wifs)) {
-		return fmt.Errorf("error to index: %s", toIndex)
+	tick, err := getTick(cli)
+	if err != nil {
+		return err
 	}
-	to := ""
-	if index == int64(len(wifs)) {
-		to, _, err = getMultiAddress(wifs)
-		if err != nil {
-			return err
-		}
-	} else {
-		to, err = bitcoin.PubKeyToAddr(wifs[index].SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-		if err != nil {
-			return err
-		}
+	indexer, err := getIndexer(cli, net)
+	if err != nil {
+		return err
+	}
+	info, err := indexer.GetTickerInfo(tick)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		return fmt.Errorf("tick %s already deployed by %s", tick, info.InscriptionId)
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
 	}
 	fmt.Printf("to: %v\n", to)
-	from, err := bitcoin.PubKeyToAddr(wifs[1].SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
+	from, wif, err := getPayer(cli, policy, net)
 	if err != nil {
 		return err
 	}
-	feerate := int64(2)
-	inscriptionId, err := brc20Mint(from, wifs[1], to, TICK, AMOUNT, feerate, NET)
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	journal, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	max, lim := cli.String("max"), cli.String("lim")
+	if lim == "" {
+		if max == "0" {
+			return fmt.Errorf("max 0 deploys a self-mint tick without a supply cap, pass --lim for the limit per mint")
+		}
+		lim = max
+	}
+	inscriptionId, err := brc20Deploy(from, wif, to, tick, max, lim, cli.Int("decimals"), cli.Int("postage"), feerate, source, journal, backend, net)
 	if err != nil {
 		return err
 	}
@@ -265,147 +644,149 @@ func mint(ctx context.Context, cli *cli.Command) error {
 }
 
 func inscribeTransferFunc(ctx context.Context, cli *cli.Command) error {
-	toIndex := cli.Args().Get(0)
-	index, err := strconv.ParseInt(toIndex, 10, 10)
+	net, err := getNetwork(cli)
 	if err != nil {
 		return err
 	}
-	wifs, err := getWIFs()
+	backend, err := getChainBackend(cli, net)
 	if err != nil {
 		return err
 	}
-	if index < 0 || index > int64(len(wifs)) {
-		return fmt.Errorf("error to index: %s", toIndex)
+	tick, err := getTick(cli)
+	if err != nil {
+		return err
 	}
-	to := ""
-	if index == int64(len(wifs)) {
-		to, _, err = getMultiAddress(wifs)
-		if err != nil {
-			return err
-		}
-	} else {
-		to, err = bitcoin.PubKeyToAddr(wifs[index].SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-		if err != nil {
-			return err
-		}
+	amount, err := getAmount(cli)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
 	}
 	fmt.Printf("to: %v\n", to)
-	from, err := bitcoin.PubKeyToAddr(wifs[1].SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
+	from, wif, err := getPayer(cli, policy, net)
 	if err != nil {
 		return err
 	}
-	feerate := int64(2)
-	inscriptionId, err := inscribeTransfer(from, wifs[1], to, TICK, "100", feerate, NET)
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	journal, err := getJournal(cli)
+	if err != nil {
+		return err
+	}
+	inscriptionIds, err := inscribeTransfer(from, wif, to, tick, amount, int(cli.Int("count")), cli.Int("postage"), feerate, source, journal, backend, net)
+	for _, inscriptionId := range inscriptionIds {
+		fmt.Println("inscriptionId: ", inscriptionId)
+	}
 	if err != nil {
 		return err
 	}
-	fmt.Println("inscriptionId: ", inscriptionId)
 	return nil
 }
 
 func listInscriptions(ctx context.Context, cli *cli.Command) error {
-	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"#", "Address", "Ticker", "InscriptionId", "amount", "Confirmations"})
-	t.AppendSeparator()
-	wifs, err := getWIFs()
+	net, err := getNetwork(cli)
 	if err != nil {
 		return err
 	}
-	for i, wif := range wifs {
-		address, err := bitcoin.PubKeyToAddr(wif.SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-		if err != nil {
-			return err
-		}
-		inscriptionRes, err := getTransferAbleInscriptions(address, os.Getenv("INDEXER_AUTH"))
-		if err != nil {
-			return err
-		}
-		if inscriptionRes.Code != 0 {
-			return fmt.Errorf("getInscriptions code: %d", inscriptionRes.Code)
-		}
-		for _, item := range inscriptionRes.Data.Inscriptions {
-			t.AppendRow([]interface{}{i, address, item.Data.Tick, item.InscriptionId, item.Data.Amt, item.Confirmations})
-		}
+	tick, err := getTick(cli)
+	if err != nil {
+		return err
 	}
-
-	multiAddress, _, err := getMultiAddress(wifs)
+	indexer, err := getIndexer(cli, net)
 	if err != nil {
 		return err
 	}
-	inscriptionRes, err := getTransferAbleInscriptions(multiAddress, os.Getenv("INDEXER_AUTH"))
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "Address", "Ticker", "InscriptionId", "amount", "Confirmations"})
+	t.AppendSeparator()
+	policy, err := getPolicy(net)
 	if err != nil {
 		return err
 	}
-	if inscriptionRes.Code != 0 {
-		return fmt.Errorf("getInscriptions code: %d", inscriptionRes.Code)
+	addresses, err := policy.addresses(net)
+	if err != nil {
+		return err
 	}
-	for _, item := range inscriptionRes.Data.Inscriptions {
-		t.AppendRow([]interface{}{3, multiAddress, item.Data.Tick, item.InscriptionId, item.Data.Amt, item.Confirmations})
+	for i, address := range addresses {
+		inscriptions, err := indexer.GetTransferableInscriptions(address, tick)
+		if err != nil {
+			return err
+		}
+		for _, item := range inscriptions {
+			t.AppendRow([]interface{}{i, address, item.Ticker, item.InscriptionId, item.Amount, item.Confirmations})
+		}
 	}
 	t.Render()
 	return nil
 }
 
 func sendInscription(ctx context.Context, cli *cli.Command) error {
-	toIndex := cli.Args().Get(0)
-	index, err := strconv.ParseInt(toIndex, 10, 10)
+	net, err := getNetwork(cli)
 	if err != nil {
 		return err
 	}
-	wifs, err := getWIFs()
+	backend, err := getChainBackend(cli, net)
 	if err != nil {
 		return err
 	}
-	if index < 0 || index > int64(len(wifs)) {
-		return fmt.Errorf("error to index: %s", toIndex)
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
 	}
-	to := ""
-	if index == int64(len(wifs)) {
-		to, _, err = getMultiAddress(wifs)
-		if err != nil {
-			return err
-		}
-	} else {
-		to, err = bitcoin.PubKeyToAddr(wifs[index].SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-		if err != nil {
-			return err
-		}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
 	}
 
-	inscriptionId := cli.Args().Get(1)
-	fmt.Printf("send %s to: %s\n", inscriptionId, to)
-	fromMultiAddress, redeemScript, err := getMultiAddress(wifs)
+	inscriptionId, err := ordinal.ParseInscriptionID(cli.Args().Get(1))
 	if err != nil {
 		return err
 	}
-	gasWif := wifs[1]
-	feeAddress, _ := bitcoin.PubKeyToAddr(gasWif.SerializePubKey(), bitcoin.SEGWIT_NATIVE, NET)
-	const feerate = 3
-	tx, err := createTx(fromMultiAddress, to, inscriptionId, feeAddress, feerate)
+	from, err := getSpender(cli.String("from"), policy, net)
 	if err != nil {
 		return err
 	}
-	tx, err = signGasInput(tx, gasWif, 1)
+	fmt.Printf("send %v from %s to: %s\n", inscriptionId, from.address, to)
+	feeAddress, gasWif, err := getPayer(cli, policy, net)
 	if err != nil {
 		return err
 	}
-	tx, signature, err := signMultiInput(tx, redeemScript, wifs[0], 0)
+	source, err := getCoinSource(cli, net)
 	if err != nil {
 		return err
 	}
-	tx, err = signMultiInputFinal(tx, redeemScript, wifs[1], 0, signature)
+	feerate, err := getFeeRate(cli, backend)
 	if err != nil {
 		return err
 	}
-	var buffer bytes.Buffer
-	err = tx.Serialize(&buffer)
+	tx, err := createTx(from, to, inscriptionId, feeAddress, cli.Int("postage"), feerate, source, backend, net)
 	if err != nil {
 		return err
 	}
-
-	// fmt.Println(hex.EncodeToString(buffer.Bytes()))
-	txId, err := postTransaction(hex.EncodeToString(buffer.Bytes()))
+	for i := 1; i < len(tx.TxIn); i++ {
+		tx, err = signGasInput(tx, gasWif, i, backend)
+		if err != nil {
+			return err
+		}
+	}
+	tx, err = from.signInput(tx, 0, backend)
+	if err != nil {
+		return err
+	}
+	txId, err := postTransaction(backend, tx)
 	if err != nil {
 		return err
 	}
@@ -1,17 +1,27 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
-
-	"github.com/btcsuite/btcd/wire"
 )
 
+// esploraBackend is a ChainBackend on the Esplora REST api served by mempool.space.
+type esploraBackend struct {
+	api    string
+	client *http.Client
+}
+
+func newEsploraBackend(api string) *esploraBackend {
+	return &esploraBackend{
+		api:    strings.TrimSuffix(api, "/"),
+		client: &http.Client{},
+	}
+}
+
 type getAddressResponse struct {
 	Address    string `json:"address"`
 	ChainStats struct {
@@ -30,17 +40,16 @@ type getAddressResponse struct {
 	} `json:"mempool_stats"`
 }
 
-func getAddress(address string) (*getAddressResponse, error) {
-	url := fmt.Sprintf("https://mempool.space/testnet/api/address/%s", address)
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
-
+func (b *esploraBackend) do(method string, path string, payload io.Reader) ([]byte, error) {
+	url := fmt.Sprintf("%s%s", b.api, path)
+	req, err := http.NewRequest(method, url, payload)
 	if err != nil {
 		return nil, err
 	}
-	res, err := client.Do(req)
+	if payload != nil {
+		req.Header.Add("Content-Type", "text/plain")
+	}
+	res, err := b.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -50,9 +59,23 @@ func getAddress(address string) (*getAddressResponse, error) {
 	if err != nil {
 		return nil, err
 	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, res.Status, body)
+	}
+	return body, nil
+}
+
+func (b *esploraBackend) GetBalance(address string) (int64, error) {
+	body, err := b.do("GET", fmt.Sprintf("/address/%s", address), nil)
+	if err != nil {
+		return 0, err
+	}
 	result := &getAddressResponse{}
 	err = json.Unmarshal(body, result)
-	return result, err
+	if err != nil {
+		return 0, err
+	}
+	return int64(result.ChainStats.FundedTxoSum - result.ChainStats.SpentTxoSum), nil
 }
 
 // {
@@ -67,33 +90,8 @@ func getAddress(address string) (*getAddressResponse, error) {
 //     "value": 41826
 // }
 
-type unspentUtxo struct {
-	Txid   string `json:"txid"`
-	Vout   int    `json:"vout"`
-	Status struct {
-		Confirmed   bool   `json:"confirmed"`
-		BlockHeight int    `json:"block_height"`
-		BlockHash   string `json:"block_hash"`
-		BlockTime   int    `json:"block_time"`
-	} `json:"status"`
-	Value int `json:"value"`
-}
-
-func getUnspentUtxo(address string) ([]*unspentUtxo, error) {
-	url := fmt.Sprintf("https://mempool.space/testnet/api/address/%s/utxo", address)
-	method := "GET"
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
-	if err != nil {
-		return nil, err
-	}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
+func (b *esploraBackend) GetUnspentUtxo(address string) ([]*unspentUtxo, error) {
+	body, err := b.do("GET", fmt.Sprintf("/address/%s/utxo", address), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -102,66 +100,60 @@ func getUnspentUtxo(address string) ([]*unspentUtxo, error) {
 	return result, err
 }
 
-func getRawTransaction(txid string) (string, error) {
-	url := fmt.Sprintf("https://mempool.space/testnet/api/tx/%s/hex", txid)
-	method := "GET"
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, nil)
-
+func (b *esploraBackend) GetRawTransaction(txid string) (string, error) {
+	body, err := b.do("GET", fmt.Sprintf("/tx/%s/hex", txid), nil)
 	if err != nil {
 		return "", err
 	}
-	res, err := client.Do(req)
-	if err != nil {
-
-		return "", err
-	}
-	defer res.Body.Close()
+	return string(body), nil
+}
 
-	body, err := io.ReadAll(res.Body)
+func (b *esploraBackend) PostTransaction(raw string) (string, error) {
+	body, err := b.do("POST", "/tx", strings.NewReader(raw))
 	if err != nil {
 		return "", err
 	}
 	return string(body), nil
 }
 
-func getTransction(txid string) (*wire.MsgTx, error) {
-	raw, err := getRawTransaction(txid)
+func (b *esploraBackend) GetBlockHeight() (int64, error) {
+	body, err := b.do("GET", "/blocks/tip/height", nil)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	tx := &wire.MsgTx{}
-	data, err := hex.DecodeString(raw)
+	return strconv.ParseInt(strings.TrimSpace(string(body)), 10, 64)
+}
+
+func (b *esploraBackend) GetRawBlock(height int64) ([]byte, error) {
+	hash, err := b.do("GET", fmt.Sprintf("/block-height/%d", height), nil)
 	if err != nil {
 		return nil, err
 	}
-	err = tx.Deserialize(bytes.NewReader(data))
-	return tx, err
+	return b.do("GET", fmt.Sprintf("/block/%s/raw", strings.TrimSpace(string(hash))), nil)
 }
 
-func postTransaction(raw string) (string, error) {
-	url := "https://mempool.space/testnet/api/tx"
-	method := "POST"
-	payload := strings.NewReader(raw)
-
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, payload)
-
+func (b *esploraBackend) EstimateFeeRate(target int) (float64, error) {
+	body, err := b.do("GET", "/fee-estimates", nil)
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	req.Header.Add("Content-Type", "text/plain")
-
-	res, err := client.Do(req)
-	if err != nil {
-		return "", err
+	estimates := make(map[string]float64)
+	if err := json.Unmarshal(body, &estimates); err != nil {
+		return 0, err
 	}
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", err
+	// esplora estimates a fixed set of targets, take the closest one not beyond target
+	best, rate := 0, 0.0
+	for key, value := range estimates {
+		blocks, err := strconv.Atoi(key)
+		if err != nil || blocks > target {
+			continue
+		}
+		if blocks > best {
+			best, rate = blocks, value
+		}
+	}
+	if best == 0 {
+		return 0, fmt.Errorf("no fee estimate within %d blocks", target)
 	}
-	return string(body), err
+	return rate, nil
 }
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// migrateMultisig moves every utxo of the multisig of from to the address of to.
+// The utxos carrying inscriptions come first, each paying an output of its own
+// value, so their sats and inscriptions keep their offsets. The rest are swept
+// into one output paying the fee, or when they cannot, paid whole to to with
+// payer paying the fee. Cardinals too small for an output of their own go to
+// the fee, never to payer.
+func migrateMultisig(from *multisigPolicy, to *multisigPolicy, payer string, payerWif *btcutil.WIF, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	fromAddress, script, err := from.address(net)
+	if err != nil {
+		return nil, err
+	}
+	keyPath := from.signsKeyPath()
+	toAddress, _, err := to.address(net)
+	if err != nil {
+		return nil, err
+	}
+	if fromAddress == toAddress {
+		return nil, fmt.Errorf("nothing to migrate, %s is both the source and the destination", fromAddress)
+	}
+	decodedToAddr, err := decodeAddress(toAddress, net)
+	if err != nil {
+		return nil, err
+	}
+	toAddrByte, err := txscript.PayToAddrScript(decodedToAddr)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := backend.GetUnspentUtxo(fromAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) == 0 {
+		return nil, fmt.Errorf("no utxos on %s", fromAddress)
+	}
+	ordinals, err := ordinalOutpoints(fromAddress, utxos, source.indexer, backend, net)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(2)
+	size := &txsize.Estimator{}
+	addInput := func(utxo *unspentUtxo) error {
+		hash, err := chainhash.NewHashFromStr(utxo.Txid)
+		if err != nil {
+			return err
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, uint32(utxo.Vout)), nil, nil))
+		from.addInputSize(size, script, keyPath)
+		return nil
+	}
+	inputsValue := int64(0)
+	for _, utxo := range utxos {
+		if !ordinals[utxoOutpoint(utxo)] {
+			continue
+		}
+		if err := addInput(utxo); err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(utxo.Value), toAddrByte))
+		inputsValue += int64(utxo.Value)
+	}
+	cardinalsValue := int64(0)
+	for _, utxo := range utxos {
+		if ordinals[utxoOutpoint(utxo)] {
+			continue
+		}
+		if err := addInput(utxo); err != nil {
+			return nil, err
+		}
+		cardinalsValue += int64(utxo.Value)
+	}
+	inputsValue += cardinalsValue
+	multisigInputs := len(tx.TxIn)
+
+	sweepSize := size.Clone()
+	sweepSize.AddOutputs(tx)
+	sweepSize.AddOutput(toAddrByte)
+	if sweep := cardinalsValue - sweepSize.Fee(feerate); sweep >= minChange {
+		tx.AddTxOut(wire.NewTxOut(sweep, toAddrByte))
+	} else {
+		if checkPostage(toAddress, cardinalsValue, net) == nil {
+			tx.AddTxOut(wire.NewTxOut(cardinalsValue, toAddrByte))
+		} else {
+			// left out of the value fundTx sees, so they pay the fee
+			inputsValue -= cardinalsValue
+		}
+		if err := fundTx(tx, payer, size, inputsValue, feerate, source, backend, net); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range tx.TxIn {
+		if i < multisigInputs {
+			tx, err = from.signMultisigInput(tx, script, i, keyPath, backend)
+		} else {
+			tx, err = signGasInput(tx, payerWif, i, backend)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func migrate(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	from, err := policy.withScriptType(cli.String("from"))
+	if err != nil {
+		return err
+	}
+	to, err := policy.withScriptType(cli.String("to"))
+	if err != nil {
+		return err
+	}
+	payer, payerWif, err := getPayer(cli, policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	tx, err := migrateMultisig(from, to, payer, payerWif, feerate, source, backend, net)
+	if err != nil {
+		return err
+	}
+	toAddress, _, err := to.address(net)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("migrate %d utxos to: %s\n", len(tx.TxIn), toAddress)
+	txId, err := postTransaction(backend, tx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("txId: ", txId)
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_MigrateMultisig(t *testing.T) {
+	tests := []struct {
+		name      string
+		to        string
+		cardinals int64
+		// whole cardinals reach the destination, the payer paying the fee
+		whole bool
+	}{
+		{name: "to p2wsh sweeping cardinals", to: multisigP2WSH, cardinals: 30000},
+		{name: "to p2sh-p2wsh with fees from the payer", to: multisigP2SHP2WSH},
+		{name: "to p2wsh with cardinals short of the fee", to: multisigP2WSH, cardinals: 1000, whole: true},
+		{name: "to p2tr", to: multisigP2TR, cardinals: 30000},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			net := &chaincfg.RegressionNetParams
+			backend := newMemoryBackend(net)
+			wifs := newTestWIFs(t, net, 3)
+			legacy := newTestPolicy(t, multisigP2SH, 2, wifs)
+			segwit, err := legacy.withScriptType(test.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			legacyAddress, _, err := legacy.address(net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			segwitAddress, _, err := segwit.address(net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			payer, err := legacy.cosignerAddress(1, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			backend.fund(t, payer, 80000)
+			source := &coinSource{strategy: coinselect.Select}
+			inscriptionId, err := inscribe(payer, wifs[1], legacyAddress, "text/plain", []byte("ord"), 546, 2, source, newTestJournal(t), backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.cardinals > 0 {
+				backend.fund(t, legacyAddress, test.cardinals)
+			}
+
+			tx, err := migrateMultisig(legacy, segwit, payer, wifs[1], 2, source, backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyTx(t, backend, tx)
+			checkFeeRate(t, backend, tx, 2)
+			if tx.TxIn[0].PreviousOutPoint.String() != fmt.Sprintf("%s:0", inscriptionId[:64]) || tx.TxOut[0].Value != 546 {
+				t.Fatalf("inscription moved from %v to an output of %d", tx.TxIn[0].PreviousOutPoint, tx.TxOut[0].Value)
+			}
+			if _, err := postTransaction(backend, tx); err != nil {
+				t.Fatal(err)
+			}
+			if balance, _ := backend.GetBalance(legacyAddress); balance != 0 {
+				t.Fatalf("legacy balance after migration: %d", balance)
+			}
+			balance, _ := backend.GetBalance(segwitAddress)
+			if test.cardinals == 0 && balance != 546 {
+				t.Fatalf("migrated balance %d", balance)
+			}
+			if test.whole && balance != 546+test.cardinals {
+				t.Fatalf("migrated balance %d, expected the cardinals whole", balance)
+			}
+			if test.cardinals > 0 && balance <= 546 {
+				t.Fatalf("cardinals not swept, migrated balance %d", balance)
+			}
+		})
+	}
+}
@@ -2,45 +2,60 @@ package main
 
 import (
 	"fmt"
-	"strconv"
+
+	"brc20tools/ordinal"
+	"brc20tools/txsize"
 
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
-const INSCRIPTION_ID_LEN = 66
-
-func createTx(fromMultiAddress string, to string, inscriptionId string, feeFrom string, feerate int64) (*wire.MsgTx, error) {
-	if len(inscriptionId) != INSCRIPTION_ID_LEN {
-		return nil, fmt.Errorf("error inscription format")
+// createTx spends the inscription held by from to to, with fees paid by
+// feeFrom. The inscription is located on its exact satpoint, and the
+// transaction is refused unless it and every other inscription on the same
+// utxo land in the output to to.
+func createTx(from *spender, to string, inscriptionId ordinal.InscriptionID, feeFrom string, postage int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	if err := checkPostage(to, postage, net); err != nil {
+		return nil, err
 	}
-	inscriptionTxId := inscriptionId[:64]
-	inscriptionN, err := strconv.Atoi(inscriptionId[65:])
+	location, err := locateInscription(inscriptionId, source.indexer, backend)
 	if err != nil {
 		return nil, err
 	}
-	inputHash, err := chainhash.NewHashFromStr(inscriptionTxId)
+	utxos, err := backend.GetUnspentUtxo(from.address)
 	if err != nil {
 		return nil, err
 	}
-	txIn := wire.NewTxIn(wire.NewOutPoint(inputHash, uint32(inscriptionN)), nil, nil)
-	tx := wire.NewMsgTx(1)
-	tx.AddTxIn(txIn)
-
-	feeInputUtxo, err := chooseMaxUtxo(feeFrom)
+	held := false
+	for _, utxo := range utxos {
+		held = held || utxoOutpoint(utxo) == location.Outpoint.String()
+	}
+	if !held {
+		return nil, fmt.Errorf("inscription %v is on %v, not an unspent output of %s", inscriptionId, location, from.address)
+	}
+	inscriptionTx, err := getTransction(backend, location.Outpoint.Txid.String())
 	if err != nil {
 		return nil, err
 	}
-	feeInputHash, err := chainhash.NewHashFromStr(feeInputUtxo.Txid)
+	inscriptionValue := inscriptionTx.TxOut[location.Outpoint.Vout].Value
+	sharing, err := newInscriptionTracer(source.indexer, backend, net).inscriptions(*location.Outpoint.Wire(), traceDepth)
 	if err != nil {
 		return nil, err
 	}
-	feeTxIn := wire.NewTxIn(wire.NewOutPoint(feeInputHash, uint32(feeInputUtxo.Vout)), nil, nil)
-	tx.AddTxIn(feeTxIn)
+	offsets := []int64{int64(location.Offset)}
+	last := int64(location.Offset)
+	for _, h := range sharing {
+		if h.id != inscriptionId {
+			offsets = append(offsets, h.offset)
+			last = max(last, h.offset)
+		}
+	}
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(location.Outpoint.Wire(), nil, nil))
 
-	decodedToAddr, err := btcutil.DecodeAddress(to, NET)
+	decodedToAddr, err := decodeAddress(to, net)
 	if err != nil {
 		return nil, err
 	}
@@ -48,46 +63,41 @@ func createTx(fromMultiAddress string, to string, inscriptionId string, feeFrom
 	if err != nil {
 		return nil, err
 	}
-	txOut := wire.NewTxOut(BRC20AMOUNT, toAddrByte)
-	tx.AddTxOut(txOut)
+	// inscriptions past the postage keep their offsets in the whole output
+	if last >= postage {
+		postage = inscriptionValue
+	}
+	tx.AddTxOut(wire.NewTxOut(postage, toAddrByte))
+	if inscriptionValue > postage {
+		fmt.Printf("%d sats of %v beyond the postage go to %s as change\n", inscriptionValue-postage, location.Outpoint, feeFrom)
+	}
 
-	docodedChangeAddr, err := btcutil.DecodeAddress(feeFrom, NET)
-	if err != nil {
+	size := &txsize.Estimator{}
+	from.addInputSize(size)
+	// the fee inputs get what the inscription output carries beyond the postage as change
+	if err := fundTx(tx, feeFrom, size, inscriptionValue, feerate, source, backend, net); err != nil {
 		return nil, err
 	}
-	changeAddrByte, err := txscript.PayToAddrScript(docodedChangeAddr)
+	fetcher, err := prevOutFetcher(tx, backend)
 	if err != nil {
 		return nil, err
 	}
-	txChangeOut := wire.NewTxOut(0, changeAddrByte)
-	tx.AddTxOut(txChangeOut)
-
-	// fee := int64(tx.SerializeSize()) * feerate
-	fee := 437 * feerate
-	tx.TxOut[1].Value = int64(feeInputUtxo.Value) + BRC20AMOUNT - fee - BRC20AMOUNT
+	if err := checkInscriptionLandings(tx, fetcher, map[int][]int64{0: offsets}, map[int]int{0: 0}); err != nil {
+		return nil, err
+	}
 	return tx, nil
 }
 
-func signGasInput(tx *wire.MsgTx, wif *btcutil.WIF, idx int) (*wire.MsgTx, error) {
+func signGasInput(tx *wire.MsgTx, wif *btcutil.WIF, idx int, backend ChainBackend) (*wire.MsgTx, error) {
 	inputUtxo := tx.TxIn[idx].PreviousOutPoint
-	preInput, err := getTransction(inputUtxo.Hash.String())
+	preInput, err := getTransction(backend, inputUtxo.Hash.String())
 	if err != nil {
 		return nil, err
 	}
 	fetcher := txscript.NewCannedPrevOutputFetcher(
 		preInput.TxOut[inputUtxo.Index].PkScript, preInput.TxOut[inputUtxo.Index].Value,
 	)
-	sighashes := txscript.NewTxSigHashes(tx, fetcher)
-	wit, err := txscript.WitnessSignature(
-		tx,
-		sighashes,
-		idx,
-		preInput.TxOut[inputUtxo.Index].Value,
-		preInput.TxOut[inputUtxo.Index].PkScript,
-		txscript.SigHashAll,
-		wif.PrivKey,
-		true,
-	)
+	wit, err := p2wpkhWitness(tx, wif, idx, fetcher)
 	if err != nil {
 		return nil, err
 	}
@@ -95,21 +105,34 @@ func signGasInput(tx *wire.MsgTx, wif *btcutil.WIF, idx int) (*wire.MsgTx, error
 	return tx, nil
 }
 
-func signMultiInput(tx *wire.MsgTx, redeemScript []byte, wif *btcutil.WIF, idx int) (*wire.MsgTx, []byte, error) {
-	signature, err := txscript.RawTxInSignature(tx, idx, redeemScript, txscript.SigHashAll, wif.PrivKey)
-	if err != nil {
-		return nil, nil, err
+// p2wpkhWitness returns the witness of wif spending P2WPKH input idx, the output
+// it spends known to fetcher, so it can be signed with no chain backend.
+func p2wpkhWitness(tx *wire.MsgTx, wif *btcutil.WIF, idx int, fetcher txscript.PrevOutputFetcher) (wire.TxWitness, error) {
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	if prevOut == nil {
+		return nil, fmt.Errorf("unknown output spent by input %d", idx)
 	}
-	return tx, signature, nil
+	sighashes := txscript.NewTxSigHashes(tx, fetcher)
+	return txscript.WitnessSignature(
+		tx,
+		sighashes,
+		idx,
+		prevOut.Value,
+		prevOut.PkScript,
+		txscript.SigHashAll,
+		wif.PrivKey,
+		true,
+	)
 }
 
-func signMultiInputFinal(tx *wire.MsgTx, redeemScript []byte, wif *btcutil.WIF, idx int, signature []byte) (*wire.MsgTx, error) {
-	signatureFinal, err := txscript.RawTxInSignature(tx, idx, redeemScript, txscript.SigHashAll, wif.PrivKey)
-	if err != nil {
-		return nil, err
-	}
+// finalizeMultiInput writes the scriptSig of input idx from signatures ordered as their keys in redeemScript.
+func finalizeMultiInput(tx *wire.MsgTx, redeemScript []byte, idx int, signatures [][]byte) (*wire.MsgTx, error) {
 	builder := txscript.NewScriptBuilder()
-	builder.AddOp(txscript.OP_FALSE).AddData(signature).AddData(signatureFinal).AddData(redeemScript)
+	builder.AddOp(txscript.OP_FALSE)
+	for _, signature := range signatures {
+		builder.AddData(signature)
+	}
+	builder.AddData(redeemScript)
 	signatureScript, err := builder.Script()
 	if err != nil {
 		return nil, err
@@ -117,3 +140,21 @@ func signMultiInputFinal(tx *wire.MsgTx, redeemScript []byte, wif *btcutil.WIF,
 	tx.TxIn[idx].SignatureScript = signatureScript
 	return tx, nil
 }
+
+// finalizeMultiWitnessInput writes the witness of input idx from signatures
+// ordered as their keys in witnessScript, and for a P2WSH nested in P2SH the
+// scriptSig pushing its witness program.
+func finalizeMultiWitnessInput(tx *wire.MsgTx, witnessScript []byte, idx int, signatures [][]byte, nested bool) (*wire.MsgTx, error) {
+	witness := wire.TxWitness{nil}
+	witness = append(witness, signatures...)
+	tx.TxIn[idx].Witness = append(witness, witnessScript)
+	tx.TxIn[idx].SignatureScript = nil
+	if nested {
+		signatureScript, err := txscript.NewScriptBuilder().AddData(p2wshScript(witnessScript)).Script()
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[idx].SignatureScript = signatureScript
+	}
+	return tx, nil
+}
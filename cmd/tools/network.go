@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/urfave/cli/v3"
+)
+
+var networks = map[string]*chaincfg.Params{
+	"mainnet":  &chaincfg.MainNetParams,
+	"testnet3": &chaincfg.TestNet3Params,
+	"signet":   &chaincfg.SigNetParams,
+	"regtest":  &chaincfg.RegressionNetParams,
+}
+
+var mempoolAPIs = map[string]string{
+	"mainnet":  "https://mempool.space/api",
+	"testnet3": "https://mempool.space/testnet/api",
+	"signet":   "https://mempool.space/signet/api",
+}
+
+var indexerAPIs = map[string]map[string]string{
+	"merlin": {
+		"testnet3": "https://testnet-api.merlinprotocol.org/apis/indexer/v1",
+	},
+	"unisat": {
+		"mainnet":  "https://open-api.unisat.io/v1/indexer",
+		"testnet3": "https://open-api-testnet.unisat.io/v1/indexer",
+	},
+	"okx": {
+		"mainnet": "https://www.oklink.com/api/v5/explorer/btc",
+	},
+}
+
+func getNetwork(cmd *cli.Command) (*chaincfg.Params, error) {
+	name := strings.ToLower(cmd.String("network"))
+	net, ok := networks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown network: %s", name)
+	}
+	return net, nil
+}
+
+// mempoolAPI returns the mempool.space api base url of net, MEMPOOL_API overrides it.
+func mempoolAPI(net *chaincfg.Params) (string, error) {
+	if url := os.Getenv("MEMPOOL_API"); url != "" {
+		return strings.TrimSuffix(url, "/"), nil
+	}
+	url, ok := mempoolAPIs[net.Name]
+	if !ok {
+		return "", fmt.Errorf("no mempool api for %s, set MEMPOOL_API", net.Name)
+	}
+	return url, nil
+}
+
+// indexerAPI returns the api base url of the named brc20 indexer on net, INDEXER_API overrides it.
+func indexerAPI(name string, net *chaincfg.Params) (string, error) {
+	if url := os.Getenv("INDEXER_API"); url != "" {
+		return strings.TrimSuffix(url, "/"), nil
+	}
+	url, ok := indexerAPIs[name][net.Name]
+	if !ok {
+		return "", fmt.Errorf("no %s indexer api for %s, set INDEXER_API", name, net.Name)
+	}
+	return url, nil
+}
+
+// decodeAddress decodes address and refuses it when it belongs to another network.
+func decodeAddress(address string, net *chaincfg.Params) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(address, net)
+	if err != nil {
+		return nil, fmt.Errorf("address %s: %w", address, err)
+	}
+	if !addr.IsForNet(net) {
+		return nil, fmt.Errorf("address %s is not a %s address", address, net.Name)
+	}
+	return addr, nil
+}
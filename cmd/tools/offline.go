@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/urfave/cli/v3"
+)
+
+// pkScriptAddress returns the address pkScript pays, or its hex when it pays none.
+func pkScriptAddress(pkScript []byte, net *chaincfg.Params) string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, net)
+	if err != nil || len(addrs) != 1 {
+		return fmt.Sprintf("script %x", pkScript)
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// describePsbt writes what signing packet does: the inputs and whose they are,
+// the outputs, the fee and where the inscriptions it moves land. It reads only
+// the packet, so it runs with no network.
+func describePsbt(w io.Writer, packet *psbt.Packet, policy *multisigPolicy, net *chaincfg.Params) error {
+	multisigPkScript, script, err := policyPkScript(policy, net)
+	if err != nil {
+		return err
+	}
+	owners := map[string]string{string(multisigPkScript): fmt.Sprintf("multisig %d-of-%d %s", policy.threshold, len(policy.cosigners), policy.scriptType)}
+	for i, c := range policy.cosigners {
+		address, err := policy.cosignerAddress(i, net)
+		if err != nil {
+			return err
+		}
+		decodedAddr, err := decodeAddress(address, net)
+		if err != nil {
+			return err
+		}
+		pkScript, err := txscript.PayToAddrScript(decodedAddr)
+		if err != nil {
+			return err
+		}
+		owners[string(pkScript)] = c.name
+	}
+
+	tx := packet.UnsignedTx
+	size := &txsize.Estimator{}
+	sized := true
+	inputsValue := int64(0)
+	inputs := table.NewWriter()
+	inputs.SetOutputMirror(w)
+	inputs.AppendHeader(table.Row{"#", "Outpoint", "Address", "Satoshi", "Owner", "Inscription"})
+	for i, txIn := range tx.TxIn {
+		input := &packet.Inputs[i]
+		// a legacy input does not sign the value it spends, so the whole previous transaction must match
+		if input.NonWitnessUtxo != nil && input.NonWitnessUtxo.TxHash() != txIn.PreviousOutPoint.Hash {
+			return fmt.Errorf("input %d: previous transaction %s does not match %s", i, input.NonWitnessUtxo.TxHash(), txIn.PreviousOutPoint.Hash)
+		}
+		prevOut, err := psbtPrevOut(packet, i)
+		if err != nil {
+			return err
+		}
+		inputsValue += prevOut.Value
+		owner := owners[string(prevOut.PkScript)]
+		switch {
+		case bytes.Equal(prevOut.PkScript, multisigPkScript):
+			policy.addInputSize(size, script, false)
+		case txscript.IsPayToWitnessPubKeyHash(prevOut.PkScript):
+			size.AddP2WPKHInput()
+		default:
+			sized = false
+		}
+		inputs.AppendRow(table.Row{i, txIn.PreviousOutPoint.String(), pkScriptAddress(prevOut.PkScript, net), prevOut.Value, owner, psbtInscription(input)})
+	}
+	inputs.Render()
+
+	outputsValue := int64(0)
+	outputs := table.NewWriter()
+	outputs.SetOutputMirror(w)
+	outputs.AppendHeader(table.Row{"#", "Address", "Satoshi", "Owner"})
+	for i, txOut := range tx.TxOut {
+		outputsValue += txOut.Value
+		outputs.AppendRow(table.Row{i, pkScriptAddress(txOut.PkScript, net), txOut.Value, owners[string(txOut.PkScript)]})
+	}
+	outputs.Render()
+	size.AddOutputs(tx)
+
+	fee := inputsValue - outputsValue
+	if fee < 0 {
+		return fmt.Errorf("outputs spend %d more than the inputs", -fee)
+	}
+	if sized {
+		fmt.Fprintf(w, "fee: %d sat, about %.1f sat/vB\n", fee, float64(fee)/float64(size.VSize()))
+	} else {
+		fmt.Fprintf(w, "fee: %d sat\n", fee)
+	}
+
+	// sats flow first in first out, an inscription on the first sat of an input
+	// lands on the output covering the same offset
+	offset := int64(0)
+	for i := range tx.TxIn {
+		prevOut, _ := psbtPrevOut(packet, i)
+		if inscriptionId := psbtInscription(&packet.Inputs[i]); inscriptionId != "" {
+			fmt.Fprintf(w, "inscription %s: input %d -> %s\n", inscriptionId, i, landing(tx.TxOut, offset, net))
+		}
+		offset += prevOut.Value
+	}
+	return nil
+}
+
+// landing describes the output of outputs covering the sat at offset.
+func landing(outputs []*wire.TxOut, offset int64, net *chaincfg.Params) string {
+	vout, _ := satLanding(outputs, offset)
+	if vout < 0 {
+		return "fee, the inscription goes to the miner"
+	}
+	return fmt.Sprintf("output %d %s", vout, pkScriptAddress(outputs[vout].PkScript, net))
+}
+
+// confirm asks the question on stdout and reports whether the answer read from r is yes.
+func confirm(question string, r io.Reader) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(r).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func signOffline(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	path := cli.Args().Get(0)
+	packet, err := readPsbt(path)
+	if err != nil {
+		return err
+	}
+	if err := describePsbt(os.Stdout, packet, policy, net); err != nil {
+		return err
+	}
+	if !cli.Bool("yes") && !confirm("sign?", os.Stdin) {
+		return fmt.Errorf("not signed")
+	}
+	signed, err := signPsbt(packet, policy, int(cli.Int("key")), net)
+	if err != nil {
+		return err
+	}
+	out := cli.String("out")
+	if out == "" {
+		out = path
+	}
+	if err := writePsbt(out, packet); err != nil {
+		return err
+	}
+	fmt.Printf("signed %d inputs: %s\n", signed, out)
+	return nil
+}
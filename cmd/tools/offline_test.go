@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func Test_DescribePsbt(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	coordinator := newTestPolicy(t, multisigP2SH, 2, wifs, 0, 1, 2)
+	signer := newTestPolicy(t, multisigP2SH, 2, wifs, 0, 1)
+	from, err := multisigSpender(coordinator, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiAddress := from.address
+	feeAddress, err := coordinator.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionId := backend.fundInscription(t, multiAddress, 546)
+	backend.fund(t, feeAddress, 50000)
+	tx, err := createTx(from, feeAddress, inscriptionId, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet, err := newSendPsbt(tx, inscriptionId, coordinator, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary bytes.Buffer
+	if err := describePsbt(&summary, packet, signer, net); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		fmt.Sprintf("inscription %s: input 0 -> output 0 %s", inscriptionId, feeAddress),
+		"multisig 2-of-3 p2sh",
+		"fee: ",
+		"sat/vB",
+	} {
+		if !strings.Contains(summary.String(), expected) {
+			t.Fatalf("summary misses %q:\n%s", expected, summary.String())
+		}
+	}
+	if _, err := signPsbt(packet, signer, 2, net); err != nil {
+		t.Fatal(err)
+	}
+
+	// a legacy input signs no value, so a substituted previous transaction must be caught
+	forged := packet.Inputs[0].NonWitnessUtxo.Copy()
+	forged.TxOut[0].Value = 1
+	forged.AddTxOut(wire.NewTxOut(0, nil))
+	packet.Inputs[0].NonWitnessUtxo = forged
+	if err := describePsbt(&summary, packet, signer, net); err == nil {
+		t.Fatal("substituted previous transaction accepted")
+	}
+}
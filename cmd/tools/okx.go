@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"brc20tools/ordinal"
+)
+
+// okxIndexer is a brc20 indexer serving the OKLink explorer api. Its
+// inscription lists carry no output value or content type, left empty.
+type okxIndexer struct {
+	api  string
+	auth string
+}
+
+type okxResponse[T any] struct {
+	Code string `json:"code"`
+	Msg  string `json:"msg"`
+	Data []T    `json:"data"`
+}
+
+func (o *okxIndexer) get(path string, query url.Values, result interface{}) error {
+	headers := map[string]string{"Ok-Access-Key": o.auth}
+	return indexerGet(fmt.Sprintf("%s%s?%s", o.api, path, query.Encode()), headers, result)
+}
+
+type okxBalanceDetails struct {
+	Page                string `json:"page"`
+	TotalPage           string `json:"totalPage"`
+	Token               string `json:"token"`
+	Balance             string `json:"balance"`
+	AvailableBalance    string `json:"availableBalance"`
+	TransferBalance     string `json:"transferBalance"`
+	TransferBalanceList []struct {
+		InscriptionId string `json:"inscriptionId"`
+		Amount        string `json:"amount"`
+	} `json:"transferBalanceList"`
+}
+
+func (o *okxIndexer) getBalanceDetails(address string, ticker string, page int) (*okxBalanceDetails, error) {
+	result := &okxResponse[*okxBalanceDetails]{}
+	query := url.Values{"address": {address}, "token": {ticker}, "page": {strconv.Itoa(page)}}
+	err := o.get("/address-balance-details", query, result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Code != "0" {
+		return nil, fmt.Errorf("okx balance details: %s (%s)", result.Msg, result.Code)
+	}
+	if len(result.Data) == 0 {
+		return nil, nil
+	}
+	return result.Data[0], nil
+}
+
+func (o *okxIndexer) GetBalance(address string, ticker string) (*brc20Balance, error) {
+	details, err := o.getBalanceDetails(address, ticker, 1)
+	if err != nil {
+		return nil, err
+	}
+	if details == nil || details.Token == "" {
+		return zeroBalance(ticker), nil
+	}
+	return &brc20Balance{
+		Ticker:           details.Token,
+		OverallBalance:   details.Balance,
+		TransferBalance:  details.TransferBalance,
+		AvailableBalance: details.AvailableBalance,
+	}, nil
+}
+
+func (o *okxIndexer) GetTransferableInscriptions(address string, ticker string) ([]*transferableInscription, error) {
+	inscriptions := make([]*transferableInscription, 0)
+	for page := 1; ; page++ {
+		details, err := o.getBalanceDetails(address, ticker, page)
+		if err != nil {
+			return nil, err
+		}
+		if details == nil {
+			return inscriptions, nil
+		}
+		for _, item := range details.TransferBalanceList {
+			inscriptions = append(inscriptions, &transferableInscription{
+				InscriptionId: item.InscriptionId,
+				Ticker:        details.Token,
+				Amount:        item.Amount,
+			})
+		}
+		totalPage, _ := strconv.Atoi(details.TotalPage)
+		if page >= totalPage {
+			return inscriptions, nil
+		}
+	}
+}
+
+func (o *okxIndexer) GetTickerInfo(ticker string) (*tickerInfo, error) {
+	result := &okxResponse[struct {
+		Token         string `json:"token"`
+		InscriptionId string `json:"inscriptionId"`
+		TotalSupply   string `json:"totalSupply"`
+		MintAmount    string `json:"mintAmount"`
+		LimitPerMint  string `json:"limitPerMint"`
+		Precision     string `json:"precision"`
+	}]{}
+	err := o.get("/token-details", url.Values{"token": {ticker}}, result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Code != "0" {
+		return nil, fmt.Errorf("okx token details: %s (%s)", result.Msg, result.Code)
+	}
+	if len(result.Data) == 0 || result.Data[0].InscriptionId == "" {
+		return nil, nil
+	}
+	data := result.Data[0]
+	decimals := maxDecimals
+	if data.Precision != "" {
+		decimals, err = strconv.Atoi(data.Precision)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &tickerInfo{
+		Ticker:        data.Token,
+		InscriptionId: data.InscriptionId,
+		Max:           data.TotalSupply,
+		Limit:         data.LimitPerMint,
+		Minted:        data.MintAmount,
+		Decimals:      decimals,
+	}, nil
+}
+
+func (o *okxIndexer) GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error) {
+	result := &okxResponse[struct {
+		InscriptionsList []struct {
+			InscriptionId string `json:"inscriptionId"`
+			Location      string `json:"location"`
+			OwnerAddress  string `json:"ownerAddress"`
+		} `json:"inscriptionsList"`
+	}]{}
+	err := o.get("/inscriptions-list", url.Values{"inscriptionId": {inscriptionId.String()}}, result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Code != "0" {
+		return nil, fmt.Errorf("okx inscriptions list: %s (%s)", result.Msg, result.Code)
+	}
+	if len(result.Data) == 0 || len(result.Data[0].InscriptionsList) == 0 {
+		return nil, fmt.Errorf("inscription not found: %v", inscriptionId)
+	}
+	item := result.Data[0].InscriptionsList[0]
+	return &inscriptionInfo{
+		InscriptionId: item.InscriptionId,
+		Address:       item.OwnerAddress,
+		Location:      item.Location,
+	}, nil
+}
+
+func (o *okxIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	inscriptions := make([]*inscriptionInfo, 0)
+	for page := 1; ; page++ {
+		result := &okxResponse[struct {
+			TotalPage        string `json:"totalPage"`
+			InscriptionsList []struct {
+				InscriptionId string `json:"inscriptionId"`
+				Location      string `json:"location"`
+				OwnerAddress  string `json:"ownerAddress"`
+			} `json:"inscriptionsList"`
+		}]{}
+		query := url.Values{"address": {address}, "page": {strconv.Itoa(page)}, "limit": {"100"}}
+		err := o.get("/inscriptions-list", query, result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Code != "0" {
+			return nil, fmt.Errorf("okx inscriptions list: %s (%s)", result.Msg, result.Code)
+		}
+		if len(result.Data) == 0 {
+			return inscriptions, nil
+		}
+		for _, item := range result.Data[0].InscriptionsList {
+			inscriptions = append(inscriptions, &inscriptionInfo{
+				InscriptionId: item.InscriptionId,
+				Address:       item.OwnerAddress,
+				Location:      item.Location,
+			})
+		}
+		totalPage, _ := strconv.Atoi(result.Data[0].TotalPage)
+		if page >= totalPage {
+			return inscriptions, nil
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/urfave/cli/v3"
+)
+
+// scanBlocks is how many recent blocks of utxos are traced for inscriptions
+// the indexer may not have seen yet.
+const scanBlocks = 6
+
+// indexedHeighter is an indexer that reports the last block it indexed, so
+// the utxos it has not seen yet are traced however far it lags.
+type indexedHeighter interface {
+	IndexedHeight() (int64, error)
+}
+
+// coinSource is where fundTx takes inputs from.
+type coinSource struct {
+	strategy coinselect.Strategy
+	// indexer labels the utxos carrying inscriptions, without it all utxos are
+	// traced for inscriptions.
+	indexer BRC20Indexer
+	// spendOrdinals lets utxos carrying inscriptions fund transactions.
+	spendOrdinals bool
+	// confirmedOnly leaves unconfirmed utxos out.
+	confirmedOnly bool
+}
+
+// getCoinSource builds the coin source chosen by --coin-selection, --indexer and --spend-ordinals.
+func getCoinSource(cmd *cli.Command, net *chaincfg.Params) (*coinSource, error) {
+	strategy, err := coinselect.ByName(cmd.String("coin-selection"))
+	if err != nil {
+		return nil, err
+	}
+	source := &coinSource{strategy: strategy, spendOrdinals: cmd.Bool("spend-ordinals")}
+	if source.spendOrdinals {
+		return source, nil
+	}
+	source.indexer, err = getIndexer(cmd, net)
+	if err != nil {
+		return nil, fmt.Errorf("labeling ordinal utxos: %w, pick an --indexer or pass --spend-ordinals", err)
+	}
+	return source, nil
+}
+
+// cardinalUtxos returns the utxos of address that are safe to spend as fees.
+func (c *coinSource) cardinalUtxos(address string, backend ChainBackend, net *chaincfg.Params) ([]*unspentUtxo, error) {
+	utxos, err := backend.GetUnspentUtxo(address)
+	if err != nil {
+		return nil, err
+	}
+	if c.confirmedOnly {
+		confirmed := make([]*unspentUtxo, 0, len(utxos))
+		for _, utxo := range utxos {
+			if utxo.Status.Confirmed {
+				confirmed = append(confirmed, utxo)
+			}
+		}
+		utxos = confirmed
+	}
+	if c.spendOrdinals {
+		return utxos, nil
+	}
+	ordinals, err := ordinalOutpoints(address, utxos, c.indexer, backend, net)
+	if err != nil {
+		return nil, err
+	}
+	cardinals := make([]*unspentUtxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if !ordinals[utxoOutpoint(utxo)] {
+			cardinals = append(cardinals, utxo)
+		}
+	}
+	return cardinals, nil
+}
+
+func utxoOutpoint(utxo *unspentUtxo) string {
+	return fmt.Sprintf("%s:%d", utxo.Txid, utxo.Vout)
+}
+
+// ordinalOutpoints returns the txid:vout of the utxos of address that carry
+// inscriptions. The indexer answers for what it has seen, the utxos it may not
+// have seen, younger than scanBlocks or than its own height, are traced back
+// through their parents for inscriptions revealed or carried into them.
+// Remote indexers do not report their height, their lag must stay within
+// scanBlocks.
+func ordinalOutpoints(address string, utxos []*unspentUtxo, indexer BRC20Indexer, backend ChainBackend, net *chaincfg.Params) (map[string]bool, error) {
+	ordinals := make(map[string]bool)
+	scanFrom := int64(0)
+	if indexer != nil {
+		inscriptions, err := indexer.GetAddressInscriptions(address)
+		if err != nil {
+			return nil, err
+		}
+		for _, inscription := range inscriptions {
+			if satpoint, err := ordinal.ParseSatpoint(inscription.Location); err == nil {
+				ordinals[satpoint.Outpoint.String()] = true
+			}
+		}
+		tip, err := backend.GetBlockHeight()
+		if err != nil {
+			return nil, err
+		}
+		scanFrom = tip - scanBlocks + 1
+		if heighted, ok := indexer.(indexedHeighter); ok {
+			height, err := heighted.IndexedHeight()
+			if err != nil {
+				return nil, err
+			}
+			scanFrom = min(scanFrom, height+1)
+		}
+	}
+	tracer := newInscriptionTracer(indexer, backend, net)
+	for _, utxo := range utxos {
+		outpoint := utxoOutpoint(utxo)
+		if ordinals[outpoint] || (utxo.Status.Confirmed && int64(utxo.Status.BlockHeight) < scanFrom) {
+			continue
+		}
+		hash, err := chainhash.NewHashFromStr(utxo.Txid)
+		if err != nil {
+			return nil, err
+		}
+		held, err := tracer.inscriptions(*wire.NewOutPoint(hash, uint32(utxo.Vout)), traceDepth)
+		if err != nil {
+			return nil, err
+		}
+		if len(held) > 0 {
+			ordinals[outpoint] = true
+		}
+	}
+	return ordinals, nil
+}
+
+func listUtxos(ctx context.Context, cmd *cli.Command) error {
+	net, err := getNetwork(cmd)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cmd, net)
+	if err != nil {
+		return err
+	}
+	indexer, err := getIndexer(cmd, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	addresses, err := policy.addresses(net)
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "Address", "Outpoint", "Satoshi", "Confirmed", "Kind"})
+	t.AppendSeparator()
+	for i, address := range addresses {
+		utxos, err := backend.GetUnspentUtxo(address)
+		if err != nil {
+			return err
+		}
+		ordinals, err := ordinalOutpoints(address, utxos, indexer, backend, net)
+		if err != nil {
+			return err
+		}
+		for _, utxo := range utxos {
+			kind := "cardinal"
+			if ordinals[utxoOutpoint(utxo)] {
+				kind = "ordinal"
+			}
+			t.AppendRow([]interface{}{i, address, utxoOutpoint(utxo), utxo.Value, strconv.FormatBool(utxo.Status.Confirmed), kind})
+		}
+	}
+	t.Render()
+	return nil
+}
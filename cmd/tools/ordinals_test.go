@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+)
+
+func Test_FundingSkipsOrdinals(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 1)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, from, 80000)
+	source := &coinSource{strategy: coinselect.Select}
+	// a fat inscription back to the wallet, the biggest utxo it has afterwards
+	inscriptionId, err := inscribe(from, wifs[0], from, "text/plain", []byte("ord"), 40000, 2, source, newTestJournal(t), backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionOutpoint := fmt.Sprintf("%s:0", inscriptionId[:64])
+	for _, tx := range backend.posted {
+		checkFeeRate(t, backend, tx, 2)
+	}
+
+	utxos, err := backend.GetUnspentUtxo(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ordinals, err := ordinalOutpoints(from, utxos, nil, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ordinals) != 1 || !ordinals[inscriptionOutpoint] {
+		t.Fatalf("ordinal utxos: %v", ordinals)
+	}
+
+	tx, err := sendSatoshi(from, wifs[0], from, 30000, 2, source, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txIn := range tx.TxIn {
+		if txIn.PreviousOutPoint.String() == inscriptionOutpoint {
+			t.Fatal("inscription spent as fee")
+		}
+	}
+	if _, err := sendSatoshi(from, wifs[0], from, 50000, 2, source, backend, net); !errors.Is(err, coinselect.ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds without the inscription, got %v", err)
+	}
+	source.spendOrdinals = true
+	if _, err := sendSatoshi(from, wifs[0], from, 50000, 2, source, backend, net); err != nil {
+		t.Fatalf("override: %v", err)
+	}
+}
+
+// staleIndexer has indexed up to height and seen no inscription yet.
+type staleIndexer struct {
+	BRC20Indexer
+	height int64
+}
+
+func (s *staleIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	return nil, nil
+}
+
+func (s *staleIndexer) IndexedHeight() (int64, error) {
+	return s.height, nil
+}
+
+// Test_OrdinalOutpointsTransferredIn labels an inscription sent to the wallet
+// from elsewhere, which no reveal in the parent of its utxo shows, while the
+// indexer lags behind.
+func Test_OrdinalOutpointsTransferredIn(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	backend.tip = 100
+	wifs := newTestWIFs(t, net, 2)
+	from, err := bitcoin.PubKeyToAddr(wifs[0].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := bitcoin.PubKeyToAddr(wifs[1].SerializePubKey(), bitcoin.SEGWIT_NATIVE, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionId := backend.fundInscription(t, other, 10000)
+	funding := backend.fund(t, from, 20000)
+
+	// other sends its first 600 sats, the inscription among them, and keeps the rest
+	backend.tip = 110
+	transfer := wire.NewMsgTx(2)
+	transfer.AddTxIn(wire.NewTxIn(ordinal.Outpoint{Txid: inscriptionId.Txid}.Wire(), nil, nil))
+	transfer.AddTxOut(wire.NewTxOut(600, funding.TxOut[0].PkScript))
+	transfer.AddTxOut(wire.NewTxOut(9000, backend.txs[inscriptionId.Txid].TxOut[0].PkScript))
+	backend.addTx(transfer)
+	backend.tip = 130
+
+	utxos, err := backend.GetUnspentUtxo(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transferred := fmt.Sprintf("%s:0", transfer.TxHash())
+	for _, test := range []struct {
+		name     string
+		indexer  BRC20Indexer
+		expected map[string]bool
+	}{
+		{name: "no indexer", expected: map[string]bool{transferred: true}},
+		// past the scan window, the lag of the indexer extends the tracing
+		{name: "stale indexer", indexer: &staleIndexer{height: 105}, expected: map[string]bool{transferred: true}},
+	} {
+		ordinals, err := ordinalOutpoints(from, utxos, test.indexer, backend, net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(ordinals) != len(test.expected) || !ordinals[transferred] {
+			t.Errorf("%s: ordinal utxos %v, expected %v", test.name, ordinals, test.expected)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/okx/go-wallet-sdk/coins/bitcoin"
+	"github.com/urfave/cli/v3"
+)
+
+// legacyCosigners are the env vars holding the keys of the original 2-of-3 policy,
+// read when MULTISIG_KEYS is not set.
+var legacyCosigners = []string{"REDEEM_SERVICES", "TREASURY_SERVICES", "TREASURY_BACKUP"}
+
+// maxCosigners is the most keys a standard CHECKMULTISIG script may hold.
+const maxCosigners = 15
+
+// the scripts a multisig policy can be paid to
+const (
+	multisigP2SH      = "p2sh"
+	multisigP2WSH     = "p2wsh"
+	multisigP2SHP2WSH = "p2sh-p2wsh"
+	multisigP2TR      = "p2tr"
+)
+
+// cosigner is a key of the multisig policy, wif is nil when only the public key is known.
+type cosigner struct {
+	name   string
+	pubKey []byte
+	wif    *btcutil.WIF
+}
+
+// multisigPolicy is the M-of-N policy guarding the multisig address, its keys in script order.
+type multisigPolicy struct {
+	threshold int
+	cosigners []*cosigner
+	// scriptType is how the multisig script is paid to, one of multisigP2SH,
+	// multisigP2WSH, multisigP2SHP2WSH or multisigP2TR.
+	scriptType string
+}
+
+// parseCosigner reads a key given as a WIF or as a hex compressed public key.
+func parseCosigner(name string, key string, net *chaincfg.Params) (*cosigner, error) {
+	key = strings.TrimSpace(key)
+	if wif, err := btcutil.DecodeWIF(key); err == nil {
+		if !wif.IsForNet(net) {
+			return nil, fmt.Errorf("%s is not a %s key", name, net.Name)
+		}
+		if !wif.CompressPubKey {
+			return nil, fmt.Errorf("%s has an uncompressed public key", name)
+		}
+		return &cosigner{name: name, pubKey: wif.SerializePubKey(), wif: wif}, nil
+	}
+	data, err := hex.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("%s is neither a WIF nor a hex public key", name)
+	}
+	pubKey, err := btcec.ParsePubKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return &cosigner{name: name, pubKey: pubKey.SerializeCompressed()}, nil
+}
+
+func newMultisigPolicy(threshold int, cosigners []*cosigner, scriptType string) (*multisigPolicy, error) {
+	if len(cosigners) == 0 || len(cosigners) > maxCosigners {
+		return nil, fmt.Errorf("multisig needs 1 to %d keys, got %d", maxCosigners, len(cosigners))
+	}
+	if threshold < 1 || threshold > len(cosigners) {
+		return nil, fmt.Errorf("multisig threshold %d out of range [1, %d]", threshold, len(cosigners))
+	}
+	switch scriptType {
+	case multisigP2SH, multisigP2WSH, multisigP2SHP2WSH, multisigP2TR:
+	default:
+		return nil, fmt.Errorf("unknown multisig type: %s", scriptType)
+	}
+	return &multisigPolicy{threshold: threshold, cosigners: cosigners, scriptType: scriptType}, nil
+}
+
+// getPolicy reads the multisig policy from MULTISIG_THRESHOLD, MULTISIG_TYPE and
+// the comma separated MULTISIG_KEYS, or else the keys of the original 2-of-3
+// from the legacy env vars. The type defaults to the legacy P2SH.
+func getPolicy(net *chaincfg.Params) (*multisigPolicy, error) {
+	threshold := 2
+	if value := os.Getenv("MULTISIG_THRESHOLD"); value != "" {
+		var err error
+		threshold, err = strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("MULTISIG_THRESHOLD: %w", err)
+		}
+	}
+	cosigners := make([]*cosigner, 0)
+	if keys := os.Getenv("MULTISIG_KEYS"); keys != "" {
+		for i, key := range strings.Split(keys, ",") {
+			c, err := parseCosigner(fmt.Sprintf("MULTISIG_KEYS[%d]", i), key, net)
+			if err != nil {
+				return nil, err
+			}
+			cosigners = append(cosigners, c)
+		}
+	} else {
+		for _, name := range legacyCosigners {
+			c, err := parseCosigner(name, os.Getenv(name), net)
+			if err != nil {
+				return nil, err
+			}
+			cosigners = append(cosigners, c)
+		}
+	}
+	scriptType := multisigP2SH
+	if value := os.Getenv("MULTISIG_TYPE"); value != "" {
+		scriptType = strings.ToLower(value)
+	}
+	return newMultisigPolicy(threshold, cosigners, scriptType)
+}
+
+// withScriptType returns the policy with the same keys paid to as scriptType.
+func (p *multisigPolicy) withScriptType(scriptType string) (*multisigPolicy, error) {
+	return newMultisigPolicy(p.threshold, p.cosigners, strings.ToLower(scriptType))
+}
+
+// multisigScript returns the CHECKMULTISIG script of the policy, the redeem script
+// of P2SH and the witness script of the segwit types, or the multi_a leaf of P2TR.
+func (p *multisigPolicy) multisigScript(net *chaincfg.Params) ([]byte, error) {
+	if p.scriptType == multisigP2TR {
+		pubKeys, err := p.pubKeys()
+		if err != nil {
+			return nil, err
+		}
+		return multiAScript(p.threshold, pubKeys)
+	}
+	addressPubKeys := make([]*btcutil.AddressPubKey, 0, len(p.cosigners))
+	for _, c := range p.cosigners {
+		addressPubKey, err := btcutil.NewAddressPubKey(c.pubKey, net)
+		if err != nil {
+			return nil, err
+		}
+		addressPubKeys = append(addressPubKeys, addressPubKey)
+	}
+	return txscript.MultiSigScript(addressPubKeys, p.threshold)
+}
+
+// address returns the address of the policy and its multisig script.
+func (p *multisigPolicy) address(net *chaincfg.Params) (string, []byte, error) {
+	script, err := p.multisigScript(net)
+	if err != nil {
+		return "", nil, err
+	}
+	var addr btcutil.Address
+	switch p.scriptType {
+	case multisigP2WSH:
+		witnessProgram := sha256.Sum256(script)
+		addr, err = btcutil.NewAddressWitnessScriptHash(witnessProgram[:], net)
+	case multisigP2SHP2WSH:
+		addr, err = btcutil.NewAddressScriptHash(p2wshScript(script), net)
+	case multisigP2TR:
+		var outputKey *btcec.PublicKey
+		outputKey, err = p.taprootOutputKey(script)
+		if err != nil {
+			return "", nil, err
+		}
+		addr, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), net)
+	default:
+		addr, err = btcutil.NewAddressScriptHashFromHash(btcutil.Hash160(script), net)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return addr.EncodeAddress(), script, nil
+}
+
+// p2wshScript returns the version 0 witness program paying to witnessScript.
+func p2wshScript(witnessScript []byte) []byte {
+	witnessProgram := sha256.Sum256(witnessScript)
+	return append([]byte{txscript.OP_0, txscript.OP_DATA_32}, witnessProgram[:]...)
+}
+
+// addInputSize adds an input spending script, the multisig script of the policy,
+// to size, through the MuSig2 key path of P2TR when keyPath is set.
+func (p *multisigPolicy) addInputSize(size *txsize.Estimator, script []byte, keyPath bool) {
+	switch p.scriptType {
+	case multisigP2WSH:
+		size.AddP2WSHMultisigInput(p.threshold, len(script))
+	case multisigP2SHP2WSH:
+		size.AddP2SHP2WSHMultisigInput(p.threshold, len(script))
+	case multisigP2TR:
+		if keyPath {
+			size.AddTaprootKeyInput()
+		} else {
+			size.AddTaprootScriptInput(p.taprootStack(), len(script), 0)
+		}
+	default:
+		size.AddP2SHMultisigInput(p.threshold, len(script))
+	}
+}
+
+// cosignerAddress returns the P2WPKH address of cosigner i.
+func (p *multisigPolicy) cosignerAddress(i int, net *chaincfg.Params) (string, error) {
+	return bitcoin.PubKeyToAddr(p.cosigners[i].pubKey, bitcoin.SEGWIT_NATIVE, net)
+}
+
+// addresses returns the addresses of the cosigners followed by the multisig address.
+func (p *multisigPolicy) addresses(net *chaincfg.Params) ([]string, error) {
+	addresses := make([]string, 0, len(p.cosigners)+1)
+	for i := range p.cosigners {
+		address, err := p.cosignerAddress(i, net)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, address)
+	}
+	multiAddress, _, err := p.address(net)
+	if err != nil {
+		return nil, err
+	}
+	return append(addresses, multiAddress), nil
+}
+
+// signMultisigInput signs input idx, which spends script, the multisig script
+// of the policy, with the first threshold cosigners holding private keys, or
+// for P2TR with keyPath set, through the MuSig2 key path with all of them.
+func (p *multisigPolicy) signMultisigInput(tx *wire.MsgTx, script []byte, idx int, keyPath bool, backend ChainBackend) (*wire.MsgTx, error) {
+	signers := 0
+	for _, c := range p.cosigners {
+		if c.wif != nil {
+			signers++
+		}
+	}
+	if signers < p.threshold {
+		return nil, fmt.Errorf("multisig needs %d signatures, only %d private keys are configured", p.threshold, signers)
+	}
+	fetcher, err := prevOutFetcher(tx, backend)
+	if err != nil {
+		return nil, err
+	}
+	if p.scriptType == multisigP2TR && keyPath {
+		if !p.keyPathSpendable() {
+			return nil, fmt.Errorf("the taproot key path needs the private key of every cosigner")
+		}
+		return p.signTaprootKeyPath(tx, script, idx, fetcher)
+	}
+	signatures := make([][]byte, len(p.cosigners))
+	signed := 0
+	for i, c := range p.cosigners {
+		if c.wif == nil || signed == p.threshold {
+			continue
+		}
+		signatures[i], err = p.multisigSignature(tx, script, idx, c.wif, fetcher)
+		if err != nil {
+			return nil, err
+		}
+		signed++
+	}
+	return p.finalizeMultisigInput(tx, script, idx, signatures)
+}
+
+// multisigSignature returns the signature of wif for input idx, which spends
+// script, the multisig script of the policy. fetcher knows the outputs tx spends.
+func (p *multisigPolicy) multisigSignature(tx *wire.MsgTx, script []byte, idx int, wif *btcutil.WIF, fetcher txscript.PrevOutputFetcher) ([]byte, error) {
+	if p.scriptType == multisigP2SH {
+		return txscript.RawTxInSignature(tx, idx, script, txscript.SigHashAll, wif.PrivKey)
+	}
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	if prevOut == nil {
+		return nil, fmt.Errorf("unknown output spent by input %d", idx)
+	}
+	sighashes := txscript.NewTxSigHashes(tx, fetcher)
+	if p.scriptType == multisigP2TR {
+		return txscript.RawTxInTapscriptSignature(tx, sighashes, idx, prevOut.Value, prevOut.PkScript, txscript.NewBaseTapLeaf(script), txscript.SigHashDefault, wif.PrivKey)
+	}
+	return txscript.RawTxInWitnessSignature(tx, sighashes, idx, prevOut.Value, script, txscript.SigHashAll, wif.PrivKey)
+}
+
+// finalizeMultisigInput writes the scriptSig and witness of input idx, which
+// spends script, from signatures indexed as the cosigners, nil for those that
+// did not sign. The first threshold signatures are used.
+func (p *multisigPolicy) finalizeMultisigInput(tx *wire.MsgTx, script []byte, idx int, signatures [][]byte) (*wire.MsgTx, error) {
+	used := make([][]byte, len(p.cosigners))
+	ordered := make([][]byte, 0, p.threshold)
+	for i, signature := range signatures {
+		if signature != nil && len(ordered) < p.threshold {
+			used[i] = signature
+			ordered = append(ordered, signature)
+		}
+	}
+	if len(ordered) < p.threshold {
+		return nil, fmt.Errorf("input %d has %d of the %d signatures the multisig needs", idx, len(ordered), p.threshold)
+	}
+	switch p.scriptType {
+	case multisigP2TR:
+		return p.finalizeTaprootLeaf(tx, script, idx, used)
+	case multisigP2SH:
+		return finalizeMultiInput(tx, script, idx, ordered)
+	default:
+		return finalizeMultiWitnessInput(tx, script, idx, ordered, p.scriptType == multisigP2SHP2WSH)
+	}
+}
+
+// getPayer returns the address and key of the cosigner chosen by --payer, who
+// pays fees and inscribes.
+func getPayer(cmd *cli.Command, policy *multisigPolicy, net *chaincfg.Params) (string, *btcutil.WIF, error) {
+	index, address, err := getPayerAddress(cmd, policy, net)
+	if err != nil {
+		return "", nil, err
+	}
+	c := policy.cosigners[index]
+	if c.wif == nil {
+		return "", nil, fmt.Errorf("payer %s has no private key", c.name)
+	}
+	return address, c.wif, nil
+}
+
+// getPayerAddress returns the index and address of the cosigner chosen by
+// --payer, whose private key may be held elsewhere.
+func getPayerAddress(cmd *cli.Command, policy *multisigPolicy, net *chaincfg.Params) (int, string, error) {
+	index := int(cmd.Int("payer"))
+	if index < 0 || index >= len(policy.cosigners) {
+		return 0, "", fmt.Errorf("payer %d out of range [0, %d)", index, len(policy.cosigners))
+	}
+	address, err := policy.cosignerAddress(index, net)
+	return index, address, err
+}
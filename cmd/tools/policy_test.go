@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func Test_ParseCosigner(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	wif := newTestWIFs(t, net, 1)[0]
+
+	c, err := parseCosigner("wif", wif.String(), net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.wif == nil || hex.EncodeToString(c.pubKey) != hex.EncodeToString(wif.SerializePubKey()) {
+		t.Fatalf("wif cosigner: %+v", c)
+	}
+	c, err = parseCosigner("pubkey", " "+hex.EncodeToString(wif.SerializePubKey())+" ", net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.wif != nil || hex.EncodeToString(c.pubKey) != hex.EncodeToString(wif.SerializePubKey()) {
+		t.Fatalf("pubkey cosigner: %+v", c)
+	}
+	if _, err := parseCosigner("mainnet", wif.String(), &chaincfg.MainNetParams); err == nil {
+		t.Fatal("expected a network mismatch")
+	}
+	if _, err := parseCosigner("garbage", "not a key", net); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
+
+func Test_PolicyBounds(t *testing.T) {
+	wifs := newTestWIFs(t, &chaincfg.RegressionNetParams, 3)
+	cosigners := newTestPolicy(t, multisigP2SH, 1, wifs).cosigners
+	for _, threshold := range []int{0, 4} {
+		if _, err := newMultisigPolicy(threshold, cosigners, multisigP2SH); err == nil {
+			t.Fatalf("threshold %d accepted", threshold)
+		}
+	}
+	if _, err := newMultisigPolicy(1, nil, multisigP2SH); err == nil {
+		t.Fatal("empty policy accepted")
+	}
+	if _, err := newMultisigPolicy(1, cosigners, "p2pkh"); err == nil {
+		t.Fatal("unknown script type accepted")
+	}
+}
+
+func Test_SignMultisigNeedsThresholdKeys(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	policy := newTestPolicy(t, multisigP2WSH, 3, newTestWIFs(t, net, 4), 1, 2)
+	_, script, err := policy.address(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, nil))
+	tx.AddTxOut(wire.NewTxOut(546, []byte{0}))
+	if _, err := policy.signMultisigInput(tx, script, 0, false, newMemoryBackend(net)); err == nil {
+		t.Fatal("signed with 2 of 3 required keys")
+	}
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// The psbt commands split send-inscription into BIP174 stages so each cosigner
+// signs on their own host: create, sign with each key, combine the copies,
+// finalize and broadcast. The MuSig2 key path of P2TR needs interactive nonce
+// rounds, so PSBTs spend it through the multi_a leaf.
+
+// psbtInscriptionKey is the proprietary PSBT input key, type 0xfc with the
+// identifier brc20tools and subtype 0, naming the inscription an input carries.
+var psbtInscriptionKey = append(append([]byte{0xfc, 10}, "brc20tools"...), 0)
+
+// psbtInscription returns the inscription input carries, or "".
+func psbtInscription(input *psbt.PInput) string {
+	for _, unknown := range input.Unknowns {
+		if bytes.Equal(unknown.Key, psbtInscriptionKey) {
+			return string(unknown.Value)
+		}
+	}
+	return ""
+}
+
+// readPsbt reads a base64 PSBT from path.
+func readPsbt(path string) (*psbt.Packet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return psbt.NewFromRawBytes(strings.NewReader(strings.TrimSpace(string(data))), true)
+}
+
+// writePsbt writes packet to path as base64.
+func writePsbt(path string, packet *psbt.Packet) error {
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(encoded+"\n"), 0o600)
+}
+
+// newSendPsbt wraps tx, spending inscriptionId from the multisig of policy on
+// its first input as createTx does and fee inputs on the rest, into a PSBT
+// carrying the outputs, scripts and inscription each cosigner needs to review
+// and sign it offline.
+func newSendPsbt(tx *wire.MsgTx, inscriptionId ordinal.InscriptionID, policy *multisigPolicy, backend ChainBackend, net *chaincfg.Params) (*psbt.Packet, error) {
+	packet, err := newMultisigPsbt(tx, policy, backend, net)
+	if err != nil {
+		return nil, err
+	}
+	input := &packet.Inputs[0]
+	input.Unknowns = append(input.Unknowns, &psbt.Unknown{Key: psbtInscriptionKey, Value: []byte(inscriptionId.String())})
+	return packet, nil
+}
+
+// newMultisigPsbt wraps tx, spending the multisig of policy and P2WPKH coins,
+// into a PSBT carrying the outputs and scripts each cosigner needs to sign it.
+func newMultisigPsbt(tx *wire.MsgTx, policy *multisigPolicy, backend ChainBackend, net *chaincfg.Params) (*psbt.Packet, error) {
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	multisigPkScript, script, err := policyPkScript(policy, net)
+	if err != nil {
+		return nil, err
+	}
+	for i, txIn := range tx.TxIn {
+		preInput, err := getTransction(backend, txIn.PreviousOutPoint.Hash.String())
+		if err != nil {
+			return nil, err
+		}
+		prevOut := preInput.TxOut[txIn.PreviousOutPoint.Index]
+		input := &packet.Inputs[i]
+		if !bytes.Equal(prevOut.PkScript, multisigPkScript) {
+			input.WitnessUtxo = prevOut
+			continue
+		}
+		switch policy.scriptType {
+		case multisigP2SH:
+			input.NonWitnessUtxo = preInput
+			input.RedeemScript = script
+		case multisigP2WSH:
+			input.WitnessUtxo = prevOut
+			input.WitnessScript = script
+		case multisigP2SHP2WSH:
+			input.WitnessUtxo = prevOut
+			input.RedeemScript = p2wshScript(script)
+			input.WitnessScript = script
+		case multisigP2TR:
+			controlBlock, err := policy.taprootControlBlock(script)
+			if err != nil {
+				return nil, err
+			}
+			controlBlockBytes, err := controlBlock.ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			root := txscript.NewBaseTapLeaf(script).TapHash()
+			input.WitnessUtxo = prevOut
+			input.TaprootInternalKey = schnorr.SerializePubKey(controlBlock.InternalKey)
+			input.TaprootMerkleRoot = root[:]
+			input.TaprootLeafScript = []*psbt.TaprootTapLeafScript{{
+				ControlBlock: controlBlockBytes,
+				Script:       script,
+				LeafVersion:  txscript.BaseLeafVersion,
+			}}
+		}
+	}
+	return packet, packet.SanityCheck()
+}
+
+// psbtSpender returns the spender of the multisig of policy for a PSBT, which
+// spends P2TR through the multi_a leaf even when every key is at hand.
+func psbtSpender(policy *multisigPolicy, net *chaincfg.Params) (*spender, error) {
+	from, err := multisigSpender(policy, net)
+	if err != nil {
+		return nil, err
+	}
+	from.keyPath = false
+	return from, nil
+}
+
+// policyPkScript returns the output script of the multisig of policy and its multisig script.
+func policyPkScript(policy *multisigPolicy, net *chaincfg.Params) ([]byte, []byte, error) {
+	address, script, err := policy.address(net)
+	if err != nil {
+		return nil, nil, err
+	}
+	decodedAddr, err := decodeAddress(address, net)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkScript, err := txscript.PayToAddrScript(decodedAddr)
+	return pkScript, script, err
+}
+
+// psbtPrevOut returns the output spent by input i of packet.
+func psbtPrevOut(packet *psbt.Packet, i int) (*wire.TxOut, error) {
+	input := packet.Inputs[i]
+	outpoint := packet.UnsignedTx.TxIn[i].PreviousOutPoint
+	switch {
+	case input.WitnessUtxo != nil:
+		return input.WitnessUtxo, nil
+	case input.NonWitnessUtxo != nil && int(outpoint.Index) < len(input.NonWitnessUtxo.TxOut):
+		return input.NonWitnessUtxo.TxOut[outpoint.Index], nil
+	default:
+		return nil, fmt.Errorf("psbt input %d has no utxo", i)
+	}
+}
+
+// psbtPrevOutFetcher returns the outputs spent by the inputs of packet.
+func psbtPrevOutFetcher(packet *psbt.Packet) (*txscript.MultiPrevOutFetcher, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, txIn := range packet.UnsignedTx.TxIn {
+		prevOut, err := psbtPrevOut(packet, i)
+		if err != nil {
+			return nil, err
+		}
+		fetcher.AddPrevOut(txIn.PreviousOutPoint, prevOut)
+	}
+	return fetcher, nil
+}
+
+// signPsbt adds the signatures of cosigner index to the inputs of packet it can
+// sign: the multisig of policy and its own P2WPKH coins. It returns how many
+// inputs it signed.
+func signPsbt(packet *psbt.Packet, policy *multisigPolicy, index int, net *chaincfg.Params) (int, error) {
+	if index < 0 || index >= len(policy.cosigners) {
+		return 0, fmt.Errorf("key %d out of range [0, %d)", index, len(policy.cosigners))
+	}
+	c := policy.cosigners[index]
+	if c.wif == nil {
+		return 0, fmt.Errorf("%s has no private key", c.name)
+	}
+	multisigPkScript, script, err := policyPkScript(policy, net)
+	if err != nil {
+		return 0, err
+	}
+	ownAddress, err := policy.cosignerAddress(index, net)
+	if err != nil {
+		return 0, err
+	}
+	decodedAddr, err := decodeAddress(ownAddress, net)
+	if err != nil {
+		return 0, err
+	}
+	ownPkScript, err := txscript.PayToAddrScript(decodedAddr)
+	if err != nil {
+		return 0, err
+	}
+	fetcher, err := psbtPrevOutFetcher(packet)
+	if err != nil {
+		return 0, err
+	}
+	tx := packet.UnsignedTx
+	signed := 0
+	for i := range tx.TxIn {
+		input := &packet.Inputs[i]
+		if input.FinalScriptSig != nil || input.FinalScriptWitness != nil {
+			continue
+		}
+		prevOut := fetcher.FetchPrevOutput(tx.TxIn[i].PreviousOutPoint)
+		if hasPartialSig(input.PartialSigs, c.pubKey) || hasTaprootSig(input.TaprootScriptSpendSig, c.pubKey[1:]) {
+			continue
+		}
+		switch {
+		case bytes.Equal(prevOut.PkScript, multisigPkScript):
+			signature, err := policy.multisigSignature(tx, script, i, c.wif, fetcher)
+			if err != nil {
+				return 0, err
+			}
+			if policy.scriptType == multisigP2TR {
+				leafHash := txscript.NewBaseTapLeaf(script).TapHash()
+				input.TaprootScriptSpendSig = append(input.TaprootScriptSpendSig, &psbt.TaprootScriptSpendSig{
+					XOnlyPubKey: c.pubKey[1:],
+					LeafHash:    leafHash[:],
+					Signature:   signature,
+					SigHash:     txscript.SigHashDefault,
+				})
+			} else {
+				input.PartialSigs = append(input.PartialSigs, &psbt.PartialSig{PubKey: c.pubKey, Signature: signature})
+			}
+		case bytes.Equal(prevOut.PkScript, ownPkScript):
+			witness, err := p2wpkhWitness(tx, c.wif, i, fetcher)
+			if err != nil {
+				return 0, err
+			}
+			input.PartialSigs = append(input.PartialSigs, &psbt.PartialSig{PubKey: c.pubKey, Signature: witness[0]})
+		default:
+			continue
+		}
+		signed++
+	}
+	if signed == 0 {
+		return 0, fmt.Errorf("%s signs no input of the psbt", c.name)
+	}
+	return signed, nil
+}
+
+// combinePsbts merges the signatures of copies of the same PSBT into the first.
+func combinePsbts(packets []*psbt.Packet) (*psbt.Packet, error) {
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("no psbt to combine")
+	}
+	combined := packets[0]
+	txid := combined.UnsignedTx.TxHash()
+	for _, packet := range packets[1:] {
+		if packet.UnsignedTx.TxHash() != txid {
+			return nil, fmt.Errorf("psbt of %s does not spend like %s", packet.UnsignedTx.TxHash(), txid)
+		}
+		for i := range packet.Inputs {
+			into, from := &combined.Inputs[i], &packet.Inputs[i]
+			if into.FinalScriptSig == nil && into.FinalScriptWitness == nil {
+				into.FinalScriptSig, into.FinalScriptWitness = from.FinalScriptSig, from.FinalScriptWitness
+			}
+			for _, partial := range from.PartialSigs {
+				if !hasPartialSig(into.PartialSigs, partial.PubKey) {
+					into.PartialSigs = append(into.PartialSigs, partial)
+				}
+			}
+			for _, spend := range from.TaprootScriptSpendSig {
+				if !hasTaprootSig(into.TaprootScriptSpendSig, spend.XOnlyPubKey) {
+					into.TaprootScriptSpendSig = append(into.TaprootScriptSpendSig, spend)
+				}
+			}
+		}
+	}
+	return combined, nil
+}
+
+func hasPartialSig(partials []*psbt.PartialSig, pubKey []byte) bool {
+	for _, partial := range partials {
+		if bytes.Equal(partial.PubKey, pubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTaprootSig(spends []*psbt.TaprootScriptSpendSig, xOnlyPubKey []byte) bool {
+	for _, spend := range spends {
+		if bytes.Equal(spend.XOnlyPubKey, xOnlyPubKey) {
+			return true
+		}
+	}
+	return false
+}
+
+// finalizePsbt writes the final scriptSig and witness of every input of packet
+// from the signatures it gathered.
+func finalizePsbt(packet *psbt.Packet, policy *multisigPolicy, net *chaincfg.Params) error {
+	multisigPkScript, script, err := policyPkScript(policy, net)
+	if err != nil {
+		return err
+	}
+	for i := range packet.Inputs {
+		input := &packet.Inputs[i]
+		if input.FinalScriptSig != nil || input.FinalScriptWitness != nil {
+			continue
+		}
+		prevOut, err := psbtPrevOut(packet, i)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(prevOut.PkScript, multisigPkScript) {
+			if err := psbt.Finalize(packet, i); err != nil {
+				return fmt.Errorf("finalize input %d: %w", i, err)
+			}
+			continue
+		}
+		signatures := make([][]byte, len(policy.cosigners))
+		for j, c := range policy.cosigners {
+			for _, partial := range input.PartialSigs {
+				if bytes.Equal(partial.PubKey, c.pubKey) {
+					signatures[j] = partial.Signature
+				}
+			}
+			for _, spend := range input.TaprootScriptSpendSig {
+				if bytes.Equal(spend.XOnlyPubKey, c.pubKey[1:]) {
+					signatures[j] = spend.Signature
+				}
+			}
+		}
+		tx, err := policy.finalizeMultisigInput(packet.UnsignedTx.Copy(), script, i, signatures)
+		if err != nil {
+			return err
+		}
+		final := psbt.NewPsbtInput(input.NonWitnessUtxo, input.WitnessUtxo)
+		final.FinalScriptSig = tx.TxIn[i].SignatureScript
+		if len(tx.TxIn[i].Witness) > 0 {
+			var witness bytes.Buffer
+			if err := psbt.WriteTxWitness(&witness, tx.TxIn[i].Witness); err != nil {
+				return err
+			}
+			final.FinalScriptWitness = witness.Bytes()
+		}
+		*input = *final
+	}
+	return nil
+}
+
+func psbtCreate(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	to, err := getToAddress(cli.Args().Get(0), policy, net)
+	if err != nil {
+		return err
+	}
+	inscriptionId, err := ordinal.ParseInscriptionID(cli.Args().Get(1))
+	if err != nil {
+		return err
+	}
+	_, feeAddress, err := getPayerAddress(cli, policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	from, err := psbtSpender(policy, net)
+	if err != nil {
+		return err
+	}
+	tx, err := createTx(from, to, inscriptionId, feeAddress, cli.Int("postage"), feerate, source, backend, net)
+	if err != nil {
+		return err
+	}
+	packet, err := newSendPsbt(tx, inscriptionId, policy, backend, net)
+	if err != nil {
+		return err
+	}
+	if err := writePsbt(cli.String("out"), packet); err != nil {
+		return err
+	}
+	fmt.Printf("send %v to %s: %s\n", inscriptionId, to, cli.String("out"))
+	return nil
+}
+
+func psbtSign(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	path := cli.Args().Get(0)
+	packet, err := readPsbt(path)
+	if err != nil {
+		return err
+	}
+	signed, err := signPsbt(packet, policy, int(cli.Int("key")), net)
+	if err != nil {
+		return err
+	}
+	out := cli.String("out")
+	if out == "" {
+		out = path
+	}
+	if err := writePsbt(out, packet); err != nil {
+		return err
+	}
+	fmt.Printf("signed %d inputs: %s\n", signed, out)
+	return nil
+}
+
+func psbtCombine(ctx context.Context, cli *cli.Command) error {
+	packets := make([]*psbt.Packet, 0, cli.Args().Len())
+	for _, path := range cli.Args().Slice() {
+		packet, err := readPsbt(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		packets = append(packets, packet)
+	}
+	combined, err := combinePsbts(packets)
+	if err != nil {
+		return err
+	}
+	return writePsbt(cli.String("out"), combined)
+}
+
+func psbtFinalize(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	path := cli.Args().Get(0)
+	packet, err := readPsbt(path)
+	if err != nil {
+		return err
+	}
+	if err := finalizePsbt(packet, policy, net); err != nil {
+		return err
+	}
+	out := cli.String("out")
+	if out == "" {
+		out = path
+	}
+	return writePsbt(out, packet)
+}
+
+func psbtBroadcast(ctx context.Context, cli *cli.Command) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	packet, err := readPsbt(cli.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	if !packet.IsComplete() {
+		return fmt.Errorf("psbt is not finalized")
+	}
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return err
+	}
+	txId, err := postTransaction(backend, tx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("txId: ", txId)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Test_PsbtWorkflow sends an inscription from a 2-of-3 multisig with each
+// stage on its own host, every host knowing only its own private key.
+func Test_PsbtWorkflow(t *testing.T) {
+	for _, scriptType := range []string{multisigP2SH, multisigP2WSH, multisigP2SHP2WSH, multisigP2TR} {
+		t.Run(scriptType, func(t *testing.T) {
+			net := &chaincfg.RegressionNetParams
+			backend := newMemoryBackend(net)
+			wifs := newTestWIFs(t, net, 3)
+			coordinator := newTestPolicy(t, scriptType, 2, wifs, 0, 1, 2)
+			payer := newTestPolicy(t, scriptType, 2, wifs, 0, 2)
+			cosigner := newTestPolicy(t, scriptType, 2, wifs, 0, 1)
+
+			// the creator holding every key still sizes the multi_a leaf
+			from, err := psbtSpender(newTestPolicy(t, scriptType, 2, wifs), net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			multiAddress := from.address
+			feeAddress, err := coordinator.cosignerAddress(1, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inscriptionId := backend.fundInscription(t, multiAddress, 546)
+			backend.fund(t, feeAddress, 50000)
+
+			tx, err := createTx(from, feeAddress, inscriptionId, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			packet, err := newSendPsbt(tx, inscriptionId, coordinator, backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			created := filepath.Join(dir, "created.psbt")
+			if err := writePsbt(created, packet); err != nil {
+				t.Fatal(err)
+			}
+
+			sign := func(policy *multisigPolicy, key int, inputs int) *psbt.Packet {
+				packet, err := readPsbt(created)
+				if err != nil {
+					t.Fatal(err)
+				}
+				signed, err := signPsbt(packet, policy, key, net)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if signed != inputs {
+					t.Fatalf("key %d signed %d inputs, expected %d", key, signed, inputs)
+				}
+				return packet
+			}
+			fromPayer := sign(payer, 1, len(tx.TxIn))
+			fromCosigner := sign(cosigner, 2, 1)
+			if _, err := signPsbt(fromCosigner, coordinator, 0, net); err == nil {
+				t.Fatal("signed without a private key")
+			}
+
+			if err := finalizePsbt(fromPayer, coordinator, net); err == nil {
+				t.Fatal("finalized with one signature")
+			}
+			combined, err := combinePsbts([]*psbt.Packet{fromPayer, fromCosigner})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := finalizePsbt(combined, coordinator, net); err != nil {
+				t.Fatal(err)
+			}
+			if !combined.IsComplete() {
+				t.Fatal("psbt not complete after finalize")
+			}
+			signedTx, err := psbt.Extract(combined)
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyTx(t, backend, signedTx)
+			checkFeeRate(t, backend, signedTx, 2)
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+
+	"brc20tools/localindex"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// revealSatpoints returns the satpoints the inscriptions revealed in tx land
+// on, in inscription order, nil for those going to fees. An inscription sits
+// on the sat its pointer asks for, or else the first sat of its input, and
+// sats flow from inputs to outputs first in first out.
+func revealSatpoints(tx *wire.MsgTx, backend ChainBackend) ([]*ordinal.Satpoint, error) {
+	envelopes := localindex.ParseEnvelopes(tx)
+	satpoints := make([]*ordinal.Satpoint, len(envelopes))
+	if len(envelopes) == 0 {
+		return satpoints, nil
+	}
+	outputsValue := int64(0)
+	for _, txOut := range tx.TxOut {
+		outputsValue += txOut.Value
+	}
+	hash := tx.TxHash()
+	// the offsets of the inputs, only fetched as far as the envelopes need
+	inputOffsets := []int64{0}
+	for i, envelope := range envelopes {
+		var offset int64
+		if envelope.Pointer != nil && *envelope.Pointer < uint64(outputsValue) {
+			offset = int64(*envelope.Pointer)
+		} else {
+			for len(inputOffsets) <= envelope.Input {
+				txIn := tx.TxIn[len(inputOffsets)-1]
+				prevTx, err := getTransction(backend, txIn.PreviousOutPoint.Hash.String())
+				if err != nil {
+					return nil, err
+				}
+				inputOffsets = append(inputOffsets, inputOffsets[len(inputOffsets)-1]+prevTx.TxOut[txIn.PreviousOutPoint.Index].Value)
+			}
+			offset = inputOffsets[envelope.Input]
+		}
+		if vout, at := satLanding(tx.TxOut, offset); vout >= 0 {
+			satpoints[i] = &ordinal.Satpoint{Outpoint: ordinal.Outpoint{Txid: hash, Vout: uint32(vout)}, Offset: uint64(at)}
+		}
+	}
+	return satpoints, nil
+}
+
+// satLanding returns the output of outputs and the offset into it the sat at
+// offset into the inputs flows to, or -1 when it goes to fees.
+func satLanding(outputs []*wire.TxOut, offset int64) (int, int64) {
+	for vout, txOut := range outputs {
+		if offset < txOut.Value {
+			return vout, offset
+		}
+		offset -= txOut.Value
+	}
+	return -1, 0
+}
+
+// locateInscription returns the current satpoint of inscriptionId. The indexer
+// knows where it moved to, without one it is looked for where it was revealed.
+func locateInscription(inscriptionId ordinal.InscriptionID, indexer BRC20Indexer, backend ChainBackend) (*ordinal.Satpoint, error) {
+	if indexer != nil {
+		info, err := indexer.GetInscription(inscriptionId)
+		if err != nil {
+			return nil, err
+		}
+		satpoint, err := ordinal.ParseSatpoint(info.Location)
+		if err != nil {
+			return nil, err
+		}
+		return &satpoint, nil
+	}
+	reveal, err := getTransction(backend, inscriptionId.Txid.String())
+	if err != nil {
+		return nil, err
+	}
+	satpoints, err := revealSatpoints(reveal, backend)
+	if err != nil {
+		return nil, err
+	}
+	if int(inscriptionId.Index) >= len(satpoints) {
+		return nil, fmt.Errorf("%s reveals no inscription %d", inscriptionId.Txid, inscriptionId.Index)
+	}
+	if satpoints[inscriptionId.Index] == nil {
+		return nil, fmt.Errorf("inscription %v was burnt to fees", inscriptionId)
+	}
+	return satpoints[inscriptionId.Index], nil
+}
+
+// checkInscriptionLandings refuses tx unless the inscriptions on its inputs,
+// their offsets keyed by input, each land on the output destinations expects
+// for that input, never in fees or another output such as change.
+func checkInscriptionLandings(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher, offsets map[int][]int64, destinations map[int]int) error {
+	inputOffset := int64(0)
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			return fmt.Errorf("unknown output spent by input %d", i)
+		}
+		for _, offset := range offsets[i] {
+			vout, _ := satLanding(tx.TxOut, inputOffset+offset)
+			if vout < 0 {
+				return fmt.Errorf("inscription at offset %d of input %d would go to fees", offset, i)
+			}
+			if vout != destinations[i] {
+				return fmt.Errorf("inscription at offset %d of input %d would land on output %d instead of %d", offset, i, vout, destinations[i])
+			}
+		}
+		inputOffset += prevOut.Value
+	}
+	return nil
+}
+
+// traceDepth is how many transactions back inscriptionTracer follows the sats
+// of an output, enough for the chains of unconfirmed spends the tools build.
+const traceDepth = 3
+
+// heldInscription is an inscription on an output, offset sats into it.
+type heldInscription struct {
+	id     ordinal.InscriptionID
+	offset int64
+}
+
+// inscriptionTracer finds the inscriptions on outputs the indexer may not have
+// seen yet: those it places there, those revealed in the transaction making
+// the output, and those the sats of its inputs carry in, first in first out.
+type inscriptionTracer struct {
+	indexer BRC20Indexer
+	backend ChainBackend
+	net     *chaincfg.Params
+	txs     map[chainhash.Hash]*wire.MsgTx
+	indexed map[string]map[ordinal.Outpoint][]*heldInscription
+}
+
+func newInscriptionTracer(indexer BRC20Indexer, backend ChainBackend, net *chaincfg.Params) *inscriptionTracer {
+	return &inscriptionTracer{
+		indexer: indexer,
+		backend: backend,
+		net:     net,
+		txs:     make(map[chainhash.Hash]*wire.MsgTx),
+		indexed: make(map[string]map[ordinal.Outpoint][]*heldInscription),
+	}
+}
+
+func (t *inscriptionTracer) tx(hash chainhash.Hash) (*wire.MsgTx, error) {
+	if tx, ok := t.txs[hash]; ok {
+		return tx, nil
+	}
+	tx, err := getTransction(t.backend, hash.String())
+	if err != nil {
+		return nil, err
+	}
+	t.txs[hash] = tx
+	return tx, nil
+}
+
+// indexedOn returns the inscriptions the indexer places on the output
+// outpoint of pkScript, asking it once per address.
+func (t *inscriptionTracer) indexedOn(outpoint ordinal.Outpoint, pkScript []byte) ([]*heldInscription, error) {
+	if t.indexer == nil {
+		return nil, nil
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, t.net)
+	if err != nil || len(addrs) != 1 {
+		return nil, nil
+	}
+	address := addrs[0].EncodeAddress()
+	byOutpoint, ok := t.indexed[address]
+	if !ok {
+		inscriptions, err := t.indexer.GetAddressInscriptions(address)
+		if err != nil {
+			return nil, err
+		}
+		byOutpoint = make(map[ordinal.Outpoint][]*heldInscription)
+		for _, inscription := range inscriptions {
+			satpoint, err := ordinal.ParseSatpoint(inscription.Location)
+			if err != nil {
+				continue
+			}
+			id, err := ordinal.ParseInscriptionID(inscription.InscriptionId)
+			if err != nil {
+				continue
+			}
+			byOutpoint[satpoint.Outpoint] = append(byOutpoint[satpoint.Outpoint], &heldInscription{id: id, offset: int64(satpoint.Offset)})
+		}
+		t.indexed[address] = byOutpoint
+	}
+	return byOutpoint[outpoint], nil
+}
+
+// inscriptions returns the inscriptions on outpoint, following the sats of
+// the inputs of its transaction depth transactions back.
+func (t *inscriptionTracer) inscriptions(outpoint wire.OutPoint, depth int) ([]*heldInscription, error) {
+	tx, err := t.tx(outpoint.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if int(outpoint.Index) >= len(tx.TxOut) {
+		return nil, fmt.Errorf("%v does not exist", outpoint)
+	}
+	held, err := t.indexedOn(ordinal.FromWire(outpoint), tx.TxOut[outpoint.Index].PkScript)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[ordinal.InscriptionID]bool)
+	for _, h := range held {
+		seen[h.id] = true
+	}
+	add := func(h *heldInscription) {
+		if !seen[h.id] {
+			seen[h.id] = true
+			held = append(held, h)
+		}
+	}
+
+	satpoints, err := revealSatpoints(tx, t.backend)
+	if err != nil {
+		return nil, err
+	}
+	for i, satpoint := range satpoints {
+		if satpoint != nil && satpoint.Outpoint.Vout == outpoint.Index {
+			add(&heldInscription{id: ordinal.InscriptionID{Txid: outpoint.Hash, Index: uint32(i)}, offset: int64(satpoint.Offset)})
+		}
+	}
+	if depth == 0 || blockchain.IsCoinBaseTx(tx) {
+		return held, nil
+	}
+
+	start := int64(0)
+	for _, txOut := range tx.TxOut[:outpoint.Index] {
+		start += txOut.Value
+	}
+	end := start + tx.TxOut[outpoint.Index].Value
+	inputOffset := int64(0)
+	for _, txIn := range tx.TxIn {
+		if inputOffset >= end {
+			break
+		}
+		parent, err := t.tx(txIn.PreviousOutPoint.Hash)
+		if err != nil {
+			return nil, err
+		}
+		value := parent.TxOut[txIn.PreviousOutPoint.Index].Value
+		if inputOffset+value > start {
+			carried, err := t.inscriptions(txIn.PreviousOutPoint, depth-1)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range carried {
+				if at := inputOffset + h.offset; at >= start && at < end {
+					add(&heldInscription{id: h.id, offset: at - start})
+				}
+			}
+		}
+		inputOffset += value
+	}
+	return held, nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Test_SendInscriptionAtOffset sends an inscription revealed by a second input,
+// which lands past the first sat of a big output, and so must move the whole output.
+func Test_SendInscriptionAtOffset(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2WSH, 2, wifs, 0, 1, 2)
+	feeAddress, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, feeAddress, 50000)
+	multiPkScript, _, err := policyPkScript(policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, err := multisigSpender(policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// input 0 carries 1000 sats, the envelope on input 1 sits on sat 1000
+	parents := backend.fund(t, feeAddress, 1000, 4000)
+	parentHash := parents.TxHash()
+	reveal := wire.NewMsgTx(2)
+	reveal.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parentHash, 0), nil, nil))
+	reveal.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&parentHash, 1), nil, nil))
+	key, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	controlBlock := append([]byte{byte(txscript.BaseLeafVersion)}, schnorr.SerializePubKey(key.PubKey())...)
+	reveal.TxIn[1].Witness = wire.TxWitness{make([]byte, 64), inscriptionScript(key.PubKey(), "text/plain", []byte("ord")), controlBlock}
+	reveal.AddTxOut(wire.NewTxOut(200, multiPkScript))
+	reveal.AddTxOut(wire.NewTxOut(4800, multiPkScript))
+	backend.addTx(reveal)
+	inscriptionId := ordinal.InscriptionID{Txid: reveal.TxHash()}
+
+	location, err := locateInscription(inscriptionId, nil, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if location.Outpoint.Vout != 1 || location.Offset != 800 {
+		t.Fatalf("inscription located on %v, expected output 1 offset 800", location)
+	}
+
+	tx, err := createTx(from, feeAddress, inscriptionId, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.TxIn[0].PreviousOutPoint != *location.Outpoint.Wire() || tx.TxOut[0].Value != 4800 {
+		t.Fatalf("spends %v into an output of %d", tx.TxIn[0].PreviousOutPoint, tx.TxOut[0].Value)
+	}
+
+	fetcher, err := prevOutFetcher(tx, backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same spend with only the postage sends the inscription to change
+	short := tx.Copy()
+	short.TxOut[0].Value = 546
+	short.TxOut = append(short.TxOut, wire.NewTxOut(4800-546, multiPkScript))
+	if err := checkInscriptionLandings(short, fetcher, map[int][]int64{0: {800}}, map[int]int{0: 0}); err == nil {
+		t.Fatal("inscription sent to change accepted")
+	}
+	short.TxOut = short.TxOut[:1]
+	if err := checkInscriptionLandings(short, fetcher, map[int][]int64{0: {600}}, map[int]int{0: 0}); err == nil || !strings.Contains(err.Error(), "fees") {
+		t.Fatalf("inscription sent to fees accepted: %v", err)
+	}
+
+	if _, err := createTx(from, feeAddress, ordinal.InscriptionID{Txid: reveal.TxHash(), Index: 1}, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select}, backend, net); err == nil {
+		t.Fatal("sent an inscription the reveal does not carry")
+	}
+}
+
+// fixedIndexer knows where a fixed set of inscriptions are.
+type fixedIndexer struct {
+	BRC20Indexer
+	inscriptions []*inscriptionInfo
+}
+
+func (f *fixedIndexer) GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error) {
+	for _, inscription := range f.inscriptions {
+		if inscription.InscriptionId == inscriptionId.String() {
+			return inscription, nil
+		}
+	}
+	return nil, fmt.Errorf("inscription not found: %v", inscriptionId)
+}
+
+func (f *fixedIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	inscriptions := make([]*inscriptionInfo, 0)
+	for _, inscription := range f.inscriptions {
+		if inscription.Address == address {
+			inscriptions = append(inscriptions, inscription)
+		}
+	}
+	return inscriptions, nil
+}
+
+// Test_SendInscriptionSharingUtxo sends either of two inscriptions merged into
+// a single utxo, which must not push the other one into change even when only
+// the other one sits past the postage.
+func Test_SendInscriptionSharingUtxo(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2WSH, 2, wifs)
+	from, err := cosignerSpender(policy, 0, false, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feeAddress, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.fund(t, feeAddress, 50000)
+
+	// the first inscription on sat 0 of the merged utxo, the second on sat 1000
+	first := backend.fundInscription(t, feeAddress, 1000)
+	second := backend.fundInscription(t, feeAddress, 1000)
+	merge := wire.NewMsgTx(2)
+	merge.AddTxIn(wire.NewTxIn(ordinal.Outpoint{Txid: first.Txid}.Wire(), nil, nil))
+	merge.AddTxIn(wire.NewTxIn(ordinal.Outpoint{Txid: second.Txid}.Wire(), nil, nil))
+	addr, err := decodeAddress(from.address, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	merge.AddTxOut(wire.NewTxOut(2000, pkScript))
+	backend.addTx(merge)
+	mergeHash := merge.TxHash()
+	source := &coinSource{strategy: coinselect.Select, indexer: &fixedIndexer{inscriptions: []*inscriptionInfo{
+		{InscriptionId: first.String(), Address: from.address, Location: mergeHash.String() + ":0:0"},
+		{InscriptionId: second.String(), Address: from.address, Location: mergeHash.String() + ":0:1000"},
+	}}}
+
+	for _, id := range []ordinal.InscriptionID{first, second} {
+		tx, err := createTx(from, feeAddress, id, feeAddress, 546, 2, source, backend, net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tx.TxIn[0].PreviousOutPoint.Hash != merge.TxHash() || tx.TxOut[0].Value != 2000 {
+			t.Fatalf("sends %v in an output of %d", id, tx.TxOut[0].Value)
+		}
+	}
+}
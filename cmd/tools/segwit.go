@@ -1,6 +1,11 @@
 package main
 
 import (
+	"fmt"
+
+	"brc20tools/coinselect"
+	"brc20tools/txsize"
+
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -8,20 +13,12 @@ import (
 	"github.com/btcsuite/btcd/wire"
 )
 
-func sendSatoshi(from string, wif *btcutil.WIF, to string, value int64, feerate int64, net *chaincfg.Params) (*wire.MsgTx, error) {
-	inputUtxo, err := chooseMaxUtxo(from)
-	if err != nil {
-		return nil, err
-	}
-	inputHash, err := chainhash.NewHashFromStr(inputUtxo.Txid)
-	if err != nil {
-		return nil, err
-	}
-	txIn := wire.NewTxIn(wire.NewOutPoint(inputHash, uint32(inputUtxo.Vout)), nil, nil)
-	tx := wire.NewMsgTx(2)
-	tx.AddTxIn(txIn)
+// minChange is the smallest change output worth creating.
+const minChange = int64(546)
+
+func sendSatoshi(from string, wif *btcutil.WIF, to string, value int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
 	//add to output
-	decodedToAddr, err := btcutil.DecodeAddress(to, NET)
+	decodedToAddr, err := decodeAddress(to, net)
 	if err != nil {
 		return nil, err
 	}
@@ -29,68 +26,98 @@ func sendSatoshi(from string, wif *btcutil.WIF, to string, value int64, feerate
 	if err != nil {
 		return nil, err
 	}
-	txOut := wire.NewTxOut(value, toAddrByte)
-	tx.AddTxOut(txOut)
-	//add change output
-	docodedChangeAddr, err := btcutil.DecodeAddress(from, NET)
-	if err != nil {
-		return nil, err
-	}
-	changeAddrByte, err := txscript.PayToAddrScript(docodedChangeAddr)
-	if err != nil {
-		return nil, err
+	return sendOutputs(from, wif, []*wire.TxOut{wire.NewTxOut(value, toAddrByte)}, feerate, source, backend, net)
+}
+
+// sendOutputs pays outputs in order from the P2WPKH address from, its change last.
+func sendOutputs(from string, wif *btcutil.WIF, outputs []*wire.TxOut, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(2)
+	for _, txOut := range outputs {
+		tx.AddTxOut(txOut)
 	}
-	txChangeOut := wire.NewTxOut(0, changeAddrByte)
-	tx.AddTxOut(txChangeOut)
-	fee := int64(tx.SerializeSize()) * feerate
-	tx.TxOut[1].Value = int64(inputUtxo.Value) - fee - value
-	preInput, err := getTransction(inputUtxo.Txid)
-	if err != nil {
+	if err := fundTx(tx, from, &txsize.Estimator{}, 0, feerate, source, backend, net); err != nil {
 		return nil, err
 	}
-	fetcher := txscript.NewCannedPrevOutputFetcher(
-		preInput.TxOut[inputUtxo.Vout].PkScript, preInput.TxOut[inputUtxo.Vout].Value,
-	)
-	sighashes := txscript.NewTxSigHashes(tx, fetcher)
-	wit, err := txscript.WitnessSignature(
-		tx,
-		sighashes,
-		0,
-		preInput.TxOut[inputUtxo.Vout].Value,
-		preInput.TxOut[inputUtxo.Vout].PkScript,
-		txscript.SigHashAll,
-		wif.PrivKey,
-		true,
-	)
-	if err != nil {
-		return nil, err
+	for i := range tx.TxIn {
+		if _, err := signGasInput(tx, wif, i, backend); err != nil {
+			return nil, err
+		}
 	}
-	tx.TxIn[0].Witness = wit
-	//test
-	// var txRaw bytes.Buffer
-	// err = tx.Serialize(&txRaw)
-	// if err != nil {
-	// 	panic(err)
-	// }
-	// fmt.Printf("wit: %v\n", tx.SerializeSize())
-	// fmt.Printf("tx.TxHash().String(): %v\n", tx.TxHash().String())
 	return tx, nil
 }
 
-func chooseMaxUtxo(from string) (*unspentUtxo, error) {
-	utxos, err := getUnspentUtxo(from)
+// fundTx adds coins of the P2WPKH address from as inputs paying the outputs of
+// tx at feerate, and change back to from when it is worth an output. size
+// estimates the inputs tx already has and inputsValue is their value. The fee
+// is sized on the final transaction, so the change absorbs estimation slack.
+func fundTx(tx *wire.MsgTx, from string, size *txsize.Estimator, inputsValue int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) error {
+	return fundTxWith(tx, from, (*txsize.Estimator).AddP2WPKHInput, size, inputsValue, feerate, source, backend, net)
+}
+
+// fundTxWith is fundTx for an address of any script type, addInput adding the
+// size of an input spending one of its coins.
+func fundTxWith(tx *wire.MsgTx, from string, addInput func(*txsize.Estimator), size *txsize.Estimator, inputsValue int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) error {
+	utxos, err := source.cardinalUtxos(from, backend, net)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var result *unspentUtxo
-	for _, uu := range utxos {
-		if result == nil {
-			result = uu
+	// the coins tx already spends, such as an inscription sent from the payer
+	spent := make(map[string]bool, len(tx.TxIn))
+	for _, txIn := range tx.TxIn {
+		spent[txIn.PreviousOutPoint.String()] = true
+	}
+	coins := make([]coinselect.Coin, 0, len(utxos))
+	for _, utxo := range utxos {
+		if spent[utxoOutpoint(utxo)] {
 			continue
 		}
-		if result.Value < uu.Value {
-			result = uu
+		coins = append(coins, coinselect.Coin{Txid: utxo.Txid, Vout: uint32(utxo.Vout), Value: int64(utxo.Value)})
+	}
+	decodedChangeAddr, err := decodeAddress(from, net)
+	if err != nil {
+		return err
+	}
+	changeAddrByte, err := txscript.PayToAddrScript(decodedChangeAddr)
+	if err != nil {
+		return err
+	}
+	size = size.Clone()
+	size.AddOutputs(tx)
+	outputsValue := int64(0)
+	for _, txOut := range tx.TxOut {
+		outputsValue += txOut.Value
+	}
+	probe := size.Clone()
+	addInput(probe)
+	inputVSize := (probe.Weight() - size.Weight() + 3) / 4
+	changeVSize := int64(wire.NewTxOut(0, changeAddrByte).SerializeSize())
+	selection, err := source.strategy(coins, coinselect.Params{
+		Target:      outputsValue + size.Fee(feerate) - inputsValue,
+		FeeRate:     feerate,
+		InputVSize:  inputVSize,
+		ChangeVSize: changeVSize,
+		ChangeCost:  (changeVSize + inputVSize) * feerate,
+		MinChange:   minChange,
+	})
+	if err != nil {
+		return fmt.Errorf("fund from %s: %w", from, err)
+	}
+	for _, coin := range selection.Coins {
+		hash, err := chainhash.NewHashFromStr(coin.Txid)
+		if err != nil {
+			return err
 		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, coin.Vout), nil, nil))
+		addInput(size)
+	}
+	available := inputsValue + selection.Value - outputsValue
+	if available < size.Fee(feerate) {
+		return fmt.Errorf("fund from %s: %w", from, coinselect.ErrInsufficientFunds)
+	}
+	size.AddOutput(changeAddrByte)
+	if change := available - size.Fee(feerate); change >= minChange {
+		//add change output
+		tx.AddTxOut(wire.NewTxOut(change, changeAddrByte))
 	}
-	return result, nil
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -36,7 +37,7 @@ func Test_Spent(t *testing.T) {
 	tx.AddTxIn(txIn)
 
 	//tb1qt7axpc0d3uek7684rf9dxwppyc0zm7njhwf6u4
-	decodedAddr, err := btcutil.DecodeAddress("tb1qt7axpc0d3uek7684rf9dxwppyc0zm7njhwf6u4", NET)
+	decodedAddr, err := btcutil.DecodeAddress("tb1qt7axpc0d3uek7684rf9dxwppyc0zm7njhwf6u4", &chaincfg.TestNet3Params)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,7 +49,7 @@ func Test_Spent(t *testing.T) {
 	txOut := wire.NewTxOut(1000, destinationAddrByte)
 	tx.AddTxOut(txOut)
 
-	decodedAddr2, err := btcutil.DecodeAddress("tb1qaxyn84qft00e5aqw9wl0jsnan0rnvvq2cvhrsh", NET)
+	decodedAddr2, err := btcutil.DecodeAddress("tb1qaxyn84qft00e5aqw9wl0jsnan0rnvvq2cvhrsh", &chaincfg.TestNet3Params)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// recipient is an output of send-btc, max taking whatever the coins of the
+// sender leave once the other outputs and the fee are paid, shared evenly
+// with the other max recipients.
+type recipient struct {
+	address string
+	value   int64
+	max     bool
+}
+
+// parseRecipients pairs the --to destinations with their --amount in sats or
+// "max", several max recipients sharing what is left evenly.
+func parseRecipients(tos []string, amounts []string, policy *multisigPolicy, net *chaincfg.Params) ([]*recipient, error) {
+	if len(tos) == 0 {
+		return nil, fmt.Errorf("no recipient")
+	}
+	if len(tos) != len(amounts) {
+		return nil, fmt.Errorf("%d recipients but %d amounts", len(tos), len(amounts))
+	}
+	recipients := make([]*recipient, 0, len(tos))
+	for i, to := range tos {
+		address, err := getToAddress(to, policy, net)
+		if err != nil {
+			return nil, err
+		}
+		r := &recipient{address: address}
+		if strings.EqualFold(amounts[i], "max") {
+			r.max = true
+		} else {
+			r.value, err = strconv.ParseInt(amounts[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("amount %q: %w", amounts[i], err)
+			}
+			if err := checkPostage(address, r.value, net); err != nil {
+				return nil, fmt.Errorf("amount %d is dust for %s", r.value, address)
+			}
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, nil
+}
+
+// sendRecipients pays recipients in order from the cardinal coins of from,
+// with change back to from, leaving the inputs unsigned. Max recipients sweep
+// all of them, the first taking the sats that do not divide evenly.
+func sendRecipients(from *spender, recipients []*recipient, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(2)
+	sweepTo := make([]int, 0)
+	fixed := int64(0)
+	for i, r := range recipients {
+		addr, err := decodeAddress(r.address, net)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(r.value, pkScript))
+		if r.max {
+			sweepTo = append(sweepTo, i)
+		}
+		fixed += r.value
+	}
+
+	if len(sweepTo) == 0 {
+		if err := fundTxWith(tx, from.address, from.addInputSize, &txsize.Estimator{}, 0, feerate, source, backend, net); err != nil {
+			return nil, err
+		}
+	} else {
+		utxos, err := source.cardinalUtxos(from.address, backend, net)
+		if err != nil {
+			return nil, err
+		}
+		if len(utxos) == 0 {
+			return nil, fmt.Errorf("no spendable utxos on %s", from.address)
+		}
+		size := &txsize.Estimator{}
+		inputsValue := int64(0)
+		for _, utxo := range utxos {
+			hash, err := chainhash.NewHashFromStr(utxo.Txid)
+			if err != nil {
+				return nil, err
+			}
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, uint32(utxo.Vout)), nil, nil))
+			from.addInputSize(size)
+			inputsValue += int64(utxo.Value)
+		}
+		size.AddOutputs(tx)
+		left := inputsValue - fixed - size.Fee(feerate)
+		share := left / int64(len(sweepTo))
+		for _, i := range sweepTo {
+			tx.TxOut[i].Value = share
+			if err := checkPostage(recipients[i].address, share, net); err != nil {
+				return nil, fmt.Errorf("%s holds %d, leaving %d to sweep after the fee", from.address, inputsValue, left)
+			}
+		}
+		tx.TxOut[sweepTo[0]].Value += left - share*int64(len(sweepTo))
+	}
+	return tx, nil
+}
+
+func sendBtc(ctx context.Context, cli *cli.Command) error {
+	return payRecipients(cli, cli.StringSlice("to"), cli.StringSlice("amount"))
+}
+
+// sweep is send-btc with the last recipient taking max, the others paid the
+// amounts given in order.
+func sweep(ctx context.Context, cli *cli.Command) error {
+	tos := cli.StringSlice("to")
+	amounts := cli.StringSlice("amount")
+	if len(amounts) != len(tos)-1 {
+		return fmt.Errorf("sweep takes an amount for each recipient but the last, got %d for %d", len(amounts), len(tos))
+	}
+	return payRecipients(cli, tos, append(amounts, "max"))
+}
+
+// payRecipients builds the transaction of send-btc and sweep, then spends it
+// with spendTx.
+func payRecipients(cli *cli.Command, tos []string, amounts []string) error {
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	from, err := getSendSpender(cli.String("from"), policy, net)
+	if err != nil {
+		return err
+	}
+	recipients, err := parseRecipients(tos, amounts, policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	tx, err := sendRecipients(from, recipients, feerate, source, backend, net)
+	if err != nil {
+		return err
+	}
+	for i, r := range recipients {
+		fmt.Printf("send %d from %s to %s\n", tx.TxOut[i].Value, from.address, r.address)
+	}
+	return spendTx(cli, from, tx, backend, net)
+}
+
+// getSendSpender resolves --from like getSpender, the multisig only as the
+// configured policy, whose cosigners sign through the psbt commands.
+func getSendSpender(arg string, policy *multisigPolicy, net *chaincfg.Params) (*spender, error) {
+	from, err := getSpender(arg, policy, net)
+	if err != nil {
+		return nil, err
+	}
+	if from.policy == nil {
+		return from, nil
+	}
+	if from.policy.scriptType != policy.scriptType {
+		return nil, fmt.Errorf("%s is a %s multisig, the psbt commands sign the configured %s one", from.address, from.policy.scriptType, policy.scriptType)
+	}
+	return psbtSpender(policy, net)
+}
+
+// spendTx signs tx from a signer address and broadcasts it, or for the
+// multisig writes it to --out as a PSBT for the cosigners to sign.
+func spendTx(cli *cli.Command, from *spender, tx *wire.MsgTx, backend ChainBackend, net *chaincfg.Params) error {
+	if from.policy != nil {
+		packet, err := newMultisigPsbt(tx, from.policy, backend, net)
+		if err != nil {
+			return err
+		}
+		if err := writePsbt(cli.String("out"), packet); err != nil {
+			return err
+		}
+		fmt.Printf("psbt: %s, sign it with psbt sign then combine and finalize\n", cli.String("out"))
+		return nil
+	}
+	tx, err := from.signTx(tx, backend)
+	if err != nil {
+		return err
+	}
+	txId, err := postTransaction(backend, tx)
+	if err != nil {
+		return err
+	}
+	fmt.Println("txId: ", txId)
+	return nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_ParseRecipients(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	policy := newTestPolicy(t, multisigP2WSH, 2, newTestWIFs(t, net, 3))
+	recipients, err := parseRecipients([]string{"0", "3"}, []string{"1000", "MAX"}, policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiAddress, _, err := policy.address(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recipients[0].value != 1000 || recipients[0].max || !recipients[1].max || recipients[1].address != multiAddress {
+		t.Fatalf("parsed %+v %+v", recipients[0], recipients[1])
+	}
+	recipients, err = parseRecipients([]string{"0", "1"}, []string{"max", "max"}, policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recipients[0].max || !recipients[1].max {
+		t.Fatalf("parsed %+v %+v", recipients[0], recipients[1])
+	}
+	for _, test := range []struct {
+		tos     []string
+		amounts []string
+	}{
+		{},
+		{tos: []string{"0", "1"}, amounts: []string{"1000"}},
+		{tos: []string{"0"}, amounts: []string{"100"}},
+		{tos: []string{"0"}, amounts: []string{"-1000"}},
+		{tos: []string{"0"}, amounts: []string{"0.1"}},
+	} {
+		if _, err := parseRecipients(test.tos, test.amounts, policy, net); err == nil {
+			t.Errorf("parseRecipients(%v, %v) should fail", test.tos, test.amounts)
+		}
+	}
+}
+
+func Test_SendBtc(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2WSH, 2, wifs)
+	from, err := cosignerSpender(policy, 0, false, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionId := backend.fundInscription(t, from.address, 60000)
+	backend.fund(t, from.address, 20000, 30000)
+	to1, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to2, err := policy.cosignerAddress(2, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := sendRecipients(from, []*recipient{{address: to1, value: 15000}, {address: to2, value: 25000}}, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = from.signTx(tx, backend); err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if tx.TxOut[0].Value != 15000 || tx.TxOut[1].Value != 25000 {
+		t.Fatalf("pays %d and %d", tx.TxOut[0].Value, tx.TxOut[1].Value)
+	}
+	for _, txIn := range tx.TxIn {
+		if txIn.PreviousOutPoint.Hash == inscriptionId.Txid {
+			t.Fatal("spent the inscription")
+		}
+	}
+	if _, err := postTransaction(backend, tx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Test_Sweep sweeps the multisig, all its cardinal coins and none of its
+// inscriptions, after paying a fixed amount first.
+func Test_Sweep(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2TR, 2, wifs, 0)
+	from, err := multisigSpender(policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inscriptionId := backend.fundInscription(t, from.address, 546)
+	backend.fund(t, from.address, 10000, 20000, 30000)
+	to1, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to2, err := policy.cosignerAddress(2, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients := []*recipient{{address: to1, value: 5000}, {address: to2, max: true}}
+	tx, err := sendRecipients(from, recipients, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = from.signTx(tx, backend); err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if len(tx.TxIn) != 3 || len(tx.TxOut) != 2 || tx.TxOut[0].Value != 5000 {
+		t.Fatalf("swept %d inputs into %d outputs", len(tx.TxIn), len(tx.TxOut))
+	}
+	if _, err := postTransaction(backend, tx); err != nil {
+		t.Fatal(err)
+	}
+	utxos, err := backend.GetUnspentUtxo(from.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 1 || utxos[0].Txid != inscriptionId.Txid.String() {
+		t.Fatalf("%d utxos left on the multisig, expected the inscription", len(utxos))
+	}
+
+	if _, err := sendRecipients(from, []*recipient{{address: to2, max: true}}, 2, &coinSource{strategy: coinselect.Select}, backend, net); err == nil {
+		t.Fatal("swept a multisig holding only an inscription")
+	}
+}
+
+// Test_SendBtcPsbt sends from the P2TR multisig through a PSBT, sized for the
+// multi_a leaf and signed by two cosigners apart.
+func Test_SendBtcPsbt(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2TR, 2, wifs)
+	from, err := getSendSpender("multisig", policy, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from.keyPath {
+		t.Fatal("psbt spends the key path")
+	}
+	backend.fund(t, from.address, 10000, 20000)
+	to, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := sendRecipients(from, []*recipient{{address: to, value: 12000}}, 2, &coinSource{strategy: coinselect.Select}, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets := make([]*psbt.Packet, 0, 2)
+	for _, key := range []int{0, 2} {
+		packet, err := newMultisigPsbt(tx, policy, backend, net)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := signPsbt(packet, policy, key, net); err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, packet)
+	}
+	combined, err := combinePsbts(packets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := finalizePsbt(combined, policy, net); err != nil {
+		t.Fatal(err)
+	}
+	signedTx, err := psbt.Extract(combined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, signedTx)
+	checkFeeRate(t, backend, signedTx, 2)
+
+	p2wsh, err := policy.withScriptType(multisigP2WSH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wshAddress, _, err := p2wsh.address(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getSendSpender(p2wshAddress, policy, net); err == nil {
+		t.Fatal("psbt from a multisig of another script type")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"brc20tools/txsize"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// spender is an address whose outputs the tool signs for: the multisig of a
+// policy, paid to any of its script types, or the P2WPKH or P2TR key path
+// address of a cosigner.
+type spender struct {
+	address string
+	// policy and script are set for the multisig, keyPath when its P2TR
+	// outputs are spent through the MuSig2 key path rather than the leaf
+	policy  *multisigPolicy
+	script  []byte
+	keyPath bool
+	// cosigner and taproot are set for a cosigner
+	cosigner *cosigner
+	taproot  bool
+}
+
+// multisigSpender returns the spender of the multisig of policy, signing
+// through the key path where the policy can.
+func multisigSpender(policy *multisigPolicy, net *chaincfg.Params) (*spender, error) {
+	address, script, err := policy.address(net)
+	if err != nil {
+		return nil, err
+	}
+	return &spender{address: address, policy: policy, script: script, keyPath: policy.signsKeyPath()}, nil
+}
+
+// cosignerSpender returns the spender of the P2WPKH address of cosigner i, or
+// of its BIP86 P2TR address when taproot is set.
+func cosignerSpender(policy *multisigPolicy, i int, taproot bool, net *chaincfg.Params) (*spender, error) {
+	c := policy.cosigners[i]
+	if !taproot {
+		address, err := policy.cosignerAddress(i, net)
+		if err != nil {
+			return nil, err
+		}
+		return &spender{address: address, cosigner: c}, nil
+	}
+	pubKey, err := btcec.ParsePubKey(c.pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	outputKey := txscript.ComputeTaprootKeyNoScript(pubKey)
+	address, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), net)
+	if err != nil {
+		return nil, err
+	}
+	return &spender{address: address.EncodeAddress(), cosigner: c, taproot: true}, nil
+}
+
+// getSpender resolves a --from argument: "multisig", a cosigner index or name,
+// or an address of the multisig or a cosigner, its script type told by which
+// of their addresses it is.
+func getSpender(arg string, policy *multisigPolicy, net *chaincfg.Params) (*spender, error) {
+	if strings.EqualFold(arg, "multisig") {
+		return multisigSpender(policy, net)
+	}
+	if index, err := strconv.Atoi(arg); err == nil {
+		if index < 0 || index > len(policy.cosigners) {
+			return nil, fmt.Errorf("signer %d out of range [0, %d]", index, len(policy.cosigners))
+		}
+		if index == len(policy.cosigners) {
+			return multisigSpender(policy, net)
+		}
+		return cosignerSpender(policy, index, false, net)
+	}
+	for i, c := range policy.cosigners {
+		if strings.EqualFold(arg, c.name) {
+			return cosignerSpender(policy, i, false, net)
+		}
+	}
+
+	addr, err := decodeAddress(arg, net)
+	if err != nil {
+		return nil, err
+	}
+	address := addr.EncodeAddress()
+	for i := range policy.cosigners {
+		for _, taproot := range []bool{false, true} {
+			s, err := cosignerSpender(policy, i, taproot, net)
+			if err != nil {
+				return nil, err
+			}
+			if s.address == address {
+				return s, nil
+			}
+		}
+	}
+	for _, scriptType := range []string{multisigP2SH, multisigP2WSH, multisigP2SHP2WSH, multisigP2TR} {
+		multisig, err := policy.withScriptType(scriptType)
+		if err != nil {
+			return nil, err
+		}
+		s, err := multisigSpender(multisig, net)
+		if err != nil {
+			return nil, err
+		}
+		if s.address == address {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not an address of the multisig or its signers", address)
+}
+
+// addInputSize adds an input spending an output of the spender to size.
+func (s *spender) addInputSize(size *txsize.Estimator) {
+	switch {
+	case s.policy != nil:
+		s.policy.addInputSize(size, s.script, s.keyPath)
+	case s.taproot:
+		size.AddTaprootKeyInput()
+	default:
+		size.AddP2WPKHInput()
+	}
+}
+
+// signInput signs input idx of tx, which spends an output of the spender.
+func (s *spender) signInput(tx *wire.MsgTx, idx int, backend ChainBackend) (*wire.MsgTx, error) {
+	if s.policy != nil {
+		return s.policy.signMultisigInput(tx, s.script, idx, s.keyPath, backend)
+	}
+	if s.cosigner.wif == nil {
+		return nil, fmt.Errorf("%s has no private key", s.cosigner.name)
+	}
+	fetcher, err := prevOutFetcher(tx, backend)
+	if err != nil {
+		return nil, err
+	}
+	if !s.taproot {
+		wit, err := p2wpkhWitness(tx, s.cosigner.wif, idx, fetcher)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[idx].Witness = wit
+		return tx, nil
+	}
+	prevOut := fetcher.FetchPrevOutput(tx.TxIn[idx].PreviousOutPoint)
+	signature, err := txscript.RawTxInTaprootSignature(tx, txscript.NewTxSigHashes(tx, fetcher), idx, prevOut.Value, prevOut.PkScript, nil, txscript.SigHashDefault, s.cosigner.wif.PrivKey)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[idx].Witness = wire.TxWitness{signature}
+	return tx, nil
+}
+
+// signTx signs every input of tx, all spending outputs of the spender.
+func (s *spender) signTx(tx *wire.MsgTx, backend ChainBackend) (*wire.MsgTx, error) {
+	for i := range tx.TxIn {
+		if _, err := s.signInput(tx, i, backend); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"testing"
+
+	"brc20tools/coinselect"
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_GetSpender(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2SH, 2, wifs)
+	multiAddress, _, err := policy.address(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer1, err := policy.cosignerAddress(1, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wsh, err := policy.withScriptType(multisigP2WSH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2wshAddress, _, err := p2wsh.address(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	taproot, err := cosignerSpender(policy, 2, true, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		arg      string
+		address  string
+		multisig bool
+	}{
+		{arg: "multisig", address: multiAddress, multisig: true},
+		{arg: "3", address: multiAddress, multisig: true},
+		{arg: "1", address: signer1},
+		{arg: "KEY1", address: signer1},
+		{arg: signer1, address: signer1},
+		{arg: p2wshAddress, address: p2wshAddress, multisig: true},
+		{arg: taproot.address, address: taproot.address},
+	} {
+		s, err := getSpender(test.arg, policy, net)
+		if err != nil {
+			t.Fatalf("getSpender(%q): %v", test.arg, err)
+		}
+		if s.address != test.address || (s.policy != nil) != test.multisig {
+			t.Errorf("getSpender(%q) spends %s", test.arg, s.address)
+		}
+	}
+	stranger := newTestPolicy(t, multisigP2SH, 1, newTestWIFs(t, net, 1))
+	strangerAddress, err := stranger.cosignerAddress(0, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, arg := range []string{"4", "-1", "treasury", strangerAddress} {
+		if _, err := getSpender(arg, policy, net); err == nil {
+			t.Errorf("getSpender(%q) should fail", arg)
+		}
+	}
+}
+
+// Test_SendInscriptionFromSigner sends inscriptions held by the P2WPKH address
+// of the payer itself and by the P2TR key path address of another signer.
+func Test_SendInscriptionFromSigner(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		signer  int
+		taproot bool
+	}{
+		{name: "payer p2wpkh", signer: 1},
+		{name: "p2tr key path", signer: 2, taproot: true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			net := &chaincfg.RegressionNetParams
+			backend := newMemoryBackend(net)
+			wifs := newTestWIFs(t, net, 3)
+			policy := newTestPolicy(t, multisigP2WSH, 2, wifs)
+			from, err := cosignerSpender(policy, test.signer, test.taproot, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			feeAddress, err := policy.cosignerAddress(1, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			to, err := policy.cosignerAddress(0, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			inscriptionId := backend.fundInscription(t, from.address, 546)
+			backend.fund(t, feeAddress, 50000)
+
+			// spending ordinals, the fee coins must still leave the inscription alone
+			tx, err := createTx(from, to, inscriptionId, feeAddress, 546, 2, &coinSource{strategy: coinselect.Select, spendOrdinals: true}, backend, net)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 1; i < len(tx.TxIn); i++ {
+				if tx.TxIn[i].PreviousOutPoint == tx.TxIn[0].PreviousOutPoint {
+					t.Fatalf("input %d spends the inscription again", i)
+				}
+				if tx, err = signGasInput(tx, wifs[1], i, backend); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tx, err = from.signInput(tx, 0, backend); err != nil {
+				t.Fatal(err)
+			}
+			verifyTx(t, backend, tx)
+			checkFeeRate(t, backend, tx, 2)
+			if _, err := postTransaction(backend, tx); err != nil {
+				t.Fatal(err)
+			}
+			if tx.TxOut[0].Value != 546 || !backend.spent(*ordinal.Outpoint{Txid: inscriptionId.Txid}.Wire()) {
+				t.Fatal("inscription did not move")
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr/musig2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// The P2TR policy commits to the MuSig2 aggregate of the cosigners as its key
+// path and to a single multi_a leaf, the threshold-of-n CHECKSIGADD script, as
+// the fallback when not every cosigner can sign.
+
+// pubKeys returns the public keys of the cosigners in script order.
+func (p *multisigPolicy) pubKeys() ([]*btcec.PublicKey, error) {
+	pubKeys := make([]*btcec.PublicKey, 0, len(p.cosigners))
+	for _, c := range p.cosigners {
+		pubKey, err := btcec.ParsePubKey(c.pubKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+	}
+	return pubKeys, nil
+}
+
+// multiAScript returns the tapscript leaf checking threshold of the schnorr signatures of pubKeys.
+func multiAScript(threshold int, pubKeys []*btcec.PublicKey) ([]byte, error) {
+	builder := txscript.NewScriptBuilder()
+	for i, pubKey := range pubKeys {
+		builder.AddData(schnorr.SerializePubKey(pubKey))
+		if i == 0 {
+			builder.AddOp(txscript.OP_CHECKSIG)
+		} else {
+			builder.AddOp(txscript.OP_CHECKSIGADD)
+		}
+	}
+	builder.AddInt64(int64(threshold))
+	builder.AddOp(txscript.OP_NUMEQUAL)
+	return builder.Script()
+}
+
+// taprootInternalKey returns the MuSig2 aggregate of pubKeys, taken in script order.
+func taprootInternalKey(pubKeys []*btcec.PublicKey) (*btcec.PublicKey, error) {
+	aggregate, _, _, err := musig2.AggregateKeys(pubKeys, false)
+	if err != nil {
+		return nil, err
+	}
+	return aggregate.PreTweakedKey, nil
+}
+
+// taprootOutputKey returns the output key committing to the aggregate of the
+// cosigners and leafScript.
+func (p *multisigPolicy) taprootOutputKey(leafScript []byte) (*btcec.PublicKey, error) {
+	pubKeys, err := p.pubKeys()
+	if err != nil {
+		return nil, err
+	}
+	internalKey, err := taprootInternalKey(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	root := txscript.NewBaseTapLeaf(leafScript).TapHash()
+	return txscript.ComputeTaprootOutputKey(internalKey, root[:]), nil
+}
+
+// keyPathSpendable reports whether every cosigner holds its private key, so
+// the policy can sign the MuSig2 key path.
+func (p *multisigPolicy) keyPathSpendable() bool {
+	for _, c := range p.cosigners {
+		if c.wif == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// signsKeyPath reports whether the policy spends its multisig through the
+// MuSig2 key path when signing in process, the cheapest spend of P2TR.
+func (p *multisigPolicy) signsKeyPath() bool {
+	return p.scriptType == multisigP2TR && p.keyPathSpendable()
+}
+
+// taprootStack returns the lengths of the multi_a signatures, an empty one
+// for each cosigner that does not sign.
+func (p *multisigPolicy) taprootStack() []int {
+	stack := make([]int, len(p.cosigners))
+	for i := 0; i < p.threshold; i++ {
+		stack[i] = schnorr.SignatureSize
+	}
+	return stack
+}
+
+// signTaprootKeyPath signs input idx, which spends the P2TR output of the
+// policy committing to leafScript, through the MuSig2 key path.
+func (p *multisigPolicy) signTaprootKeyPath(tx *wire.MsgTx, leafScript []byte, idx int, fetcher txscript.PrevOutputFetcher) (*wire.MsgTx, error) {
+	pubKeys, err := p.pubKeys()
+	if err != nil {
+		return nil, err
+	}
+	sighashes := txscript.NewTxSigHashes(tx, fetcher)
+	sighash, err := txscript.CalcTaprootSignatureHash(sighashes, txscript.SigHashDefault, tx, idx, fetcher)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := p.musig2Sign(pubKeys, txscript.NewBaseTapLeaf(leafScript), sighash)
+	if err != nil {
+		return nil, err
+	}
+	tx.TxIn[idx].Witness = wire.TxWitness{signature}
+	return tx, nil
+}
+
+// taprootControlBlock returns the control block revealing leafScript, the only
+// leaf of the P2TR output of the policy.
+func (p *multisigPolicy) taprootControlBlock(leafScript []byte) (*txscript.ControlBlock, error) {
+	pubKeys, err := p.pubKeys()
+	if err != nil {
+		return nil, err
+	}
+	internalKey, err := taprootInternalKey(pubKeys)
+	if err != nil {
+		return nil, err
+	}
+	tree := txscript.AssembleTaprootScriptTree(txscript.NewBaseTapLeaf(leafScript))
+	controlBlock := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+	return &controlBlock, nil
+}
+
+// finalizeTaprootLeaf writes the witness spending input idx through the
+// multi_a leafScript from signatures indexed as the cosigners, nil for those
+// that do not sign.
+func (p *multisigPolicy) finalizeTaprootLeaf(tx *wire.MsgTx, leafScript []byte, idx int, signatures [][]byte) (*wire.MsgTx, error) {
+	controlBlock, err := p.taprootControlBlock(leafScript)
+	if err != nil {
+		return nil, err
+	}
+	controlBlockBytes, err := controlBlock.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	// the stack is consumed from the top, so the signature of the first key comes last
+	witness := make(wire.TxWitness, 0, len(signatures)+2)
+	for i := len(signatures) - 1; i >= 0; i-- {
+		witness = append(witness, signatures[i])
+	}
+	tx.TxIn[idx].Witness = append(witness, leafScript, controlBlockBytes)
+	return tx, nil
+}
+
+// musig2Sign runs the two MuSig2 rounds between the cosigners in process, so
+// every cosigner key must be local, and returns their aggregate signature of
+// sighash for the key path of the output committing to leaf. Cosigners signing
+// apart go through the psbt commands, which spend the multi_a leaf.
+func (p *multisigPolicy) musig2Sign(pubKeys []*btcec.PublicKey, leaf txscript.TapLeaf, sighash []byte) ([]byte, error) {
+	root := leaf.TapHash()
+	var msg [32]byte
+	copy(msg[:], sighash)
+
+	nonces := make([]*musig2.Nonces, 0, len(p.cosigners))
+	pubNonces := make([][musig2.PubNonceSize]byte, 0, len(p.cosigners))
+	for i := range p.cosigners {
+		nonce, err := musig2.GenNonces(musig2.WithPublicKey(pubKeys[i]))
+		if err != nil {
+			return nil, err
+		}
+		nonces = append(nonces, nonce)
+		pubNonces = append(pubNonces, nonce.PubNonce)
+	}
+	combinedNonce, err := musig2.AggregateNonces(pubNonces)
+	if err != nil {
+		return nil, err
+	}
+	partials := make([]*musig2.PartialSignature, 0, len(p.cosigners))
+	for i, c := range p.cosigners {
+		partial, err := musig2.Sign(nonces[i].SecNonce, c.wif.PrivKey, combinedNonce, pubKeys, msg, musig2.WithTaprootSignTweak(root[:]))
+		if err != nil {
+			return nil, err
+		}
+		partials = append(partials, partial)
+	}
+	signature := musig2.CombineSigs(partials[0].R, partials, musig2.WithTaprootTweakedCombine(msg, pubKeys, root[:], false))
+	return signature.Serialize(), nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+
+	"brc20tools/ordinal"
+)
+
+// unisatIndexer is a brc20 indexer serving the UniSat open api.
+type unisatIndexer struct {
+	api  string
+	auth string
+}
+
+// unisatPageSize is the page size used for the list endpoints.
+const unisatPageSize = 100
+
+type unisatResponse[T any] struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+	Data T      `json:"data"`
+}
+
+func (u *unisatIndexer) get(path string, result interface{}) error {
+	headers := map[string]string{"Authorization": fmt.Sprintf("Bearer %s", u.auth)}
+	return indexerGet(fmt.Sprintf("%s%s", u.api, path), headers, result)
+}
+
+func (u *unisatIndexer) GetBalance(address string, ticker string) (*brc20Balance, error) {
+	result := &unisatResponse[*struct {
+		Ticker              string `json:"ticker"`
+		OverallBalance      string `json:"overallBalance"`
+		TransferableBalance string `json:"transferableBalance"`
+		AvailableBalance    string `json:"availableBalance"`
+	}]{}
+	err := u.get(fmt.Sprintf("/address/%s/brc20/%s/info", address, ticker), result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Data == nil || indexerNotFound(result.Code, result.Msg) {
+		return zeroBalance(ticker), nil
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("unisat balance: %s (%d)", result.Msg, result.Code)
+	}
+	return &brc20Balance{
+		Ticker:           result.Data.Ticker,
+		OverallBalance:   result.Data.OverallBalance,
+		TransferBalance:  result.Data.TransferableBalance,
+		AvailableBalance: result.Data.AvailableBalance,
+	}, nil
+}
+
+func (u *unisatIndexer) GetTransferableInscriptions(address string, ticker string) ([]*transferableInscription, error) {
+	inscriptions := make([]*transferableInscription, 0)
+	for {
+		result := &unisatResponse[struct {
+			Total  int `json:"total"`
+			Detail []struct {
+				Data struct {
+					Op   string `json:"op"`
+					Tick string `json:"tick"`
+					Amt  string `json:"amt"`
+				} `json:"data"`
+				InscriptionId string `json:"inscriptionId"`
+				Confirmations int    `json:"confirmations"`
+			} `json:"detail"`
+		}]{}
+		path := fmt.Sprintf("/address/%s/brc20/%s/transferable-inscriptions?start=%d&limit=%d", address, ticker, len(inscriptions), unisatPageSize)
+		err := u.get(path, result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Code != 0 {
+			return nil, fmt.Errorf("unisat transferable inscriptions: %s (%d)", result.Msg, result.Code)
+		}
+		for _, item := range result.Data.Detail {
+			inscriptions = append(inscriptions, &transferableInscription{
+				InscriptionId: item.InscriptionId,
+				Ticker:        item.Data.Tick,
+				Amount:        item.Data.Amt,
+				Confirmations: item.Confirmations,
+			})
+		}
+		if len(result.Data.Detail) == 0 || len(inscriptions) >= result.Data.Total {
+			return inscriptions, nil
+		}
+	}
+}
+
+func (u *unisatIndexer) GetTickerInfo(ticker string) (*tickerInfo, error) {
+	result := &unisatResponse[*struct {
+		Ticker        string `json:"ticker"`
+		InscriptionId string `json:"inscriptionId"`
+		Max           string `json:"max"`
+		Limit         string `json:"limit"`
+		Minted        string `json:"minted"`
+		Decimal       int    `json:"decimal"`
+	}]{}
+	err := u.get(fmt.Sprintf("/brc20/%s/info", ticker), result)
+	if err != nil {
+		return nil, err
+	}
+	if indexerNotFound(result.Code, result.Msg) || (result.Code == 0 && result.Data == nil) {
+		return nil, nil
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("unisat ticker info: %s (%d)", result.Msg, result.Code)
+	}
+	return &tickerInfo{
+		Ticker:        result.Data.Ticker,
+		InscriptionId: result.Data.InscriptionId,
+		Max:           result.Data.Max,
+		Limit:         result.Data.Limit,
+		Minted:        result.Data.Minted,
+		Decimals:      result.Data.Decimal,
+	}, nil
+}
+
+func (u *unisatIndexer) GetInscription(inscriptionId ordinal.InscriptionID) (*inscriptionInfo, error) {
+	result := &unisatResponse[struct {
+		InscriptionId string `json:"inscriptionId"`
+		Address       string `json:"address"`
+		Location      string `json:"location"`
+		OutputValue   int64  `json:"outputValue"`
+		ContentType   string `json:"contentType"`
+	}]{}
+	err := u.get(fmt.Sprintf("/inscription/info/%s", inscriptionId), result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Code != 0 {
+		return nil, fmt.Errorf("unisat inscription: %s (%d)", result.Msg, result.Code)
+	}
+	return &inscriptionInfo{
+		InscriptionId: result.Data.InscriptionId,
+		Address:       result.Data.Address,
+		Location:      result.Data.Location,
+		OutputValue:   result.Data.OutputValue,
+		ContentType:   result.Data.ContentType,
+	}, nil
+}
+
+func (u *unisatIndexer) GetAddressInscriptions(address string) ([]*inscriptionInfo, error) {
+	inscriptions := make([]*inscriptionInfo, 0)
+	for {
+		result := &unisatResponse[struct {
+			Total       int `json:"total"`
+			Inscription []struct {
+				InscriptionId string `json:"inscriptionId"`
+				Address       string `json:"address"`
+				Location      string `json:"location"`
+				OutputValue   int64  `json:"outputValue"`
+				ContentType   string `json:"contentType"`
+			} `json:"inscription"`
+		}]{}
+		path := fmt.Sprintf("/address/%s/inscription-data?cursor=%d&size=%d", address, len(inscriptions), unisatPageSize)
+		err := u.get(path, result)
+		if err != nil {
+			return nil, err
+		}
+		if result.Code != 0 {
+			return nil, fmt.Errorf("unisat address inscriptions: %s (%d)", result.Msg, result.Code)
+		}
+		for _, item := range result.Data.Inscription {
+			inscriptions = append(inscriptions, &inscriptionInfo{
+				InscriptionId: item.InscriptionId,
+				Address:       item.Address,
+				Location:      item.Location,
+				OutputValue:   item.OutputValue,
+				ContentType:   item.ContentType,
+			})
+		}
+		if len(result.Data.Inscription) == 0 || len(inscriptions) >= result.Data.Total {
+			return inscriptions, nil
+		}
+	}
+}
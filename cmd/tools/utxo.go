@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/urfave/cli/v3"
+)
+
+// consolidateUtxos merges the cardinal utxos of from into one output back to
+// it, refusing above maxFeeRate where merging costs more than it saves later.
+func consolidateUtxos(from *spender, maxFeeRate int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	if feerate > maxFeeRate {
+		return nil, fmt.Errorf("fee rate %d sat/vB is above %d, consolidate when fees are lower", feerate, maxFeeRate)
+	}
+	utxos, err := source.cardinalUtxos(from.address, backend, net)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) < 2 {
+		return nil, fmt.Errorf("%s has %d cardinal utxos, nothing to consolidate", from.address, len(utxos))
+	}
+	return sendRecipients(from, []*recipient{{address: from.address, max: true}}, feerate, source, backend, net)
+}
+
+// splitUtxos pays count outputs of amount back to from, or when amount is 0,
+// splits all its cardinal utxos into count even outputs.
+func splitUtxos(from *spender, count int, amount int64, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("split into %d outputs", count)
+	}
+	recipients := make([]*recipient, 0, count)
+	for i := 0; i < count; i++ {
+		recipients = append(recipients, &recipient{address: from.address, value: amount, max: amount == 0})
+	}
+	if amount != 0 {
+		if err := checkPostage(from.address, amount, net); err != nil {
+			return nil, err
+		}
+	}
+	return sendRecipients(from, recipients, feerate, source, backend, net)
+}
+
+func utxoConsolidate(ctx context.Context, cli *cli.Command) error {
+	return sendUtxoTx(cli, func(from *spender, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+		return consolidateUtxos(from, cli.Int("below"), feerate, source, backend, net)
+	})
+}
+
+func utxoSplit(ctx context.Context, cli *cli.Command) error {
+	return sendUtxoTx(cli, func(from *spender, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error) {
+		return splitUtxos(from, int(cli.Int("count")), cli.Int("amount"), feerate, source, backend, net)
+	})
+}
+
+// sendUtxoTx builds the transaction of a utxo command with build, from the
+// --from address, then spends it with spendTx. Ordinal utxos are never spent.
+func sendUtxoTx(cli *cli.Command, build func(from *spender, feerate int64, source *coinSource, backend ChainBackend, net *chaincfg.Params) (*wire.MsgTx, error)) error {
+	if cli.Bool("spend-ordinals") {
+		return fmt.Errorf("utxo commands only spend cardinal utxos, drop --spend-ordinals")
+	}
+	net, err := getNetwork(cli)
+	if err != nil {
+		return err
+	}
+	backend, err := getChainBackend(cli, net)
+	if err != nil {
+		return err
+	}
+	policy, err := getPolicy(net)
+	if err != nil {
+		return err
+	}
+	from, err := getSendSpender(cli.String("from"), policy, net)
+	if err != nil {
+		return err
+	}
+	source, err := getCoinSource(cli, net)
+	if err != nil {
+		return err
+	}
+	feerate, err := getFeeRate(cli, backend)
+	if err != nil {
+		return err
+	}
+	tx, err := build(from, feerate, source, backend, net)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d utxos into %d\n", from.address, len(tx.TxIn), len(tx.TxOut))
+	return spendTx(cli, from, tx, backend, net)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+
+	"brc20tools/coinselect"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+func Test_ConsolidateUtxos(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2WSH, 2, wifs)
+	from, err := cosignerSpender(policy, 1, false, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &coinSource{strategy: coinselect.Select}
+	inscriptionId := backend.fundInscription(t, from.address, 546)
+	backend.fund(t, from.address, 3000)
+
+	if _, err := consolidateUtxos(from, 5, 2, source, backend, net); err == nil {
+		t.Fatal("consolidated a single cardinal utxo")
+	}
+	backend.fund(t, from.address, 1000, 1000, 2000)
+	if _, err := consolidateUtxos(from, 5, 10, source, backend, net); err == nil {
+		t.Fatal("consolidated above the fee rate ceiling")
+	}
+	tx, err := consolidateUtxos(from, 5, 2, source, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = from.signTx(tx, backend); err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if len(tx.TxIn) != 4 || len(tx.TxOut) != 1 {
+		t.Fatalf("consolidated %d utxos into %d", len(tx.TxIn), len(tx.TxOut))
+	}
+	if _, err := postTransaction(backend, tx); err != nil {
+		t.Fatal(err)
+	}
+	utxos, err := backend.GetUnspentUtxo(from.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 2 {
+		t.Fatalf("%d utxos left, expected the inscription and the consolidated one", len(utxos))
+	}
+	for _, utxo := range utxos {
+		if utxo.Txid != inscriptionId.Txid.String() && utxo.Txid != tx.TxHash().String() {
+			t.Fatalf("unexpected utxo %s", utxoOutpoint(utxo))
+		}
+	}
+}
+
+func Test_SplitUtxos(t *testing.T) {
+	net := &chaincfg.RegressionNetParams
+	backend := newMemoryBackend(net)
+	wifs := newTestWIFs(t, net, 3)
+	policy := newTestPolicy(t, multisigP2WSH, 2, wifs)
+	from, err := cosignerSpender(policy, 0, false, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &coinSource{strategy: coinselect.Select}
+	backend.fundInscription(t, from.address, 546)
+	backend.fund(t, from.address, 100000)
+
+	tx, err := splitUtxos(from, 5, 10000, 2, source, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = from.signTx(tx, backend); err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if len(tx.TxIn) != 1 || len(tx.TxOut) != 6 {
+		t.Fatalf("split %d utxos into %d, expected 5 and change", len(tx.TxIn), len(tx.TxOut))
+	}
+	for _, txOut := range tx.TxOut[:5] {
+		if txOut.Value != 10000 {
+			t.Fatalf("split into %d", txOut.Value)
+		}
+	}
+	if _, err := postTransaction(backend, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rest split evenly, the odd sats on the first output
+	tx, err = splitUtxos(from, 3, 0, 2, source, backend, net)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = from.signTx(tx, backend); err != nil {
+		t.Fatal(err)
+	}
+	verifyTx(t, backend, tx)
+	checkFeeRate(t, backend, tx, 2)
+	if len(tx.TxIn) != 6 || len(tx.TxOut) != 3 {
+		t.Fatalf("split %d utxos into %d", len(tx.TxIn), len(tx.TxOut))
+	}
+	if share := tx.TxOut[1].Value; tx.TxOut[2].Value != share || tx.TxOut[0].Value-share < 0 || tx.TxOut[0].Value-share >= 3 {
+		t.Fatalf("uneven split %d %d %d", tx.TxOut[0].Value, tx.TxOut[1].Value, tx.TxOut[2].Value)
+	}
+
+	if _, err := splitUtxos(from, 2, 100, 2, source, backend, net); err == nil {
+		t.Fatal("split into dust")
+	}
+	if _, err := splitUtxos(from, 0, 1000, 2, source, backend, net); err == nil {
+		t.Fatal("split into no outputs")
+	}
+}
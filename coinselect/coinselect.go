@@ -0,0 +1,297 @@
+// Package coinselect picks the coins that fund a transaction.
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// ErrInsufficientFunds is returned when all coins together cannot pay the target.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// ErrNoChangelessMatch is returned by BranchAndBound when no subset pays the
+// target without change.
+var ErrNoChangelessMatch = errors.New("no changeless coin selection")
+
+// Coin is a spendable output.
+type Coin struct {
+	Txid  string
+	Vout  uint32
+	Value int64
+}
+
+// Params are what a selection has to pay for, sizes are in virtual bytes and
+// fees in satoshi per virtual byte.
+type Params struct {
+	// Target is the value of the outputs plus the fee of the transaction without
+	// the selected inputs and change. It may be negative when inputs that are
+	// already in the transaction pay for more than the outputs.
+	Target  int64
+	FeeRate int64
+	// InputVSize is what each selected input adds to the transaction.
+	InputVSize int64
+	// ChangeVSize is the size of the change output.
+	ChangeVSize int64
+	// ChangeCost is the cost of creating a change output and spending it later,
+	// a changeless selection may overpay the target by up to this much.
+	ChangeCost int64
+	// MinChange is the smallest change output worth creating, smaller change
+	// is left to the fee.
+	MinChange int64
+}
+
+// Selection is the outcome of a strategy.
+type Selection struct {
+	Coins []Coin
+	// Value is the sum of the coin values.
+	Value int64
+	// Change is the value of the change output, zero when there is none.
+	Change int64
+}
+
+// Strategy selects coins paying p.
+type Strategy func(coins []Coin, p Params) (*Selection, error)
+
+// Strategies are the strategies by the names the tools accept.
+var Strategies = map[string]Strategy{
+	"auto":          Select,
+	"bnb":           BranchAndBound,
+	"knapsack":      Knapsack,
+	"largest-first": LargestFirst,
+}
+
+// ByName returns the strategy called name.
+func ByName(name string) (Strategy, error) {
+	strategy, ok := Strategies[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown coin selection: %s", name)
+	}
+	return strategy, nil
+}
+
+// Select looks for a changeless selection first and falls back to Knapsack.
+func Select(coins []Coin, p Params) (*Selection, error) {
+	selection, err := BranchAndBound(coins, p)
+	if err == nil || !errors.Is(err, ErrNoChangelessMatch) {
+		return selection, err
+	}
+	return Knapsack(coins, p)
+}
+
+// candidate is a coin with its value net of the fee to spend it.
+type candidate struct {
+	coin      Coin
+	effective int64
+}
+
+// candidates returns the coins worth spending at p.FeeRate, largest effective value first.
+func candidates(coins []Coin, p Params) ([]candidate, int64) {
+	pool := make([]candidate, 0, len(coins))
+	total := int64(0)
+	for _, coin := range coins {
+		effective := coin.Value - p.InputVSize*p.FeeRate
+		if effective <= 0 {
+			continue
+		}
+		pool = append(pool, candidate{coin: coin, effective: effective})
+		total += effective
+	}
+	sort.SliceStable(pool, func(i, j int) bool {
+		return pool[i].effective > pool[j].effective
+	})
+	return pool, total
+}
+
+func (p Params) changeFee() int64 {
+	return p.ChangeVSize * p.FeeRate
+}
+
+// withChange is the effective value a selection needs to pay the target and a change output.
+func (p Params) withChange() int64 {
+	return p.Target + p.changeFee() + p.MinChange
+}
+
+// finish turns selected into a selection, with change when the excess is worth one.
+func finish(selected []candidate, p Params) (*Selection, error) {
+	selection := &Selection{Coins: make([]Coin, 0, len(selected))}
+	effective := int64(0)
+	for _, c := range selected {
+		selection.Coins = append(selection.Coins, c.coin)
+		selection.Value += c.coin.Value
+		effective += c.effective
+	}
+	if effective < p.Target {
+		return nil, ErrInsufficientFunds
+	}
+	if change := effective - p.Target - p.changeFee(); change >= p.MinChange && change > 0 {
+		selection.Change = change
+	}
+	return selection, nil
+}
+
+// bnbMaxTries bounds the search of BranchAndBound.
+const bnbMaxTries = 100000
+
+// BranchAndBound searches for the subset that pays the target without change and
+// overpays it the least, at most by p.ChangeCost.
+func BranchAndBound(coins []Coin, p Params) (*Selection, error) {
+	pool, total := candidates(coins, p)
+	if total < p.Target {
+		return nil, ErrInsufficientFunds
+	}
+	upper := p.Target + p.ChangeCost
+	var best []candidate
+	bestExcess := int64(-1)
+	selected := make([]candidate, 0, len(pool))
+	tries := 0
+	var search func(i int, value int64, remaining int64)
+	search = func(i int, value int64, remaining int64) {
+		tries++
+		if tries > bnbMaxTries || value > upper || value+remaining < p.Target {
+			return
+		}
+		if value >= p.Target {
+			if excess := value - p.Target; bestExcess < 0 || excess < bestExcess {
+				best = append(best[:0], selected...)
+				bestExcess = excess
+			}
+			return
+		}
+		if i == len(pool) {
+			return
+		}
+		selected = append(selected, pool[i])
+		search(i+1, value+pool[i].effective, remaining-pool[i].effective)
+		selected = selected[:len(selected)-1]
+		// leaving out a coin equal to the one just tried repeats that branch
+		next := i + 1
+		for next < len(pool) && pool[next].effective == pool[i].effective {
+			remaining -= pool[next].effective
+			next++
+		}
+		search(next, value, remaining-pool[i].effective)
+	}
+	search(0, 0, total)
+	if bestExcess < 0 {
+		return nil, ErrNoChangelessMatch
+	}
+	selection, err := finish(best, p)
+	if err != nil {
+		return nil, err
+	}
+	// the excess is below the change cost and goes to the fee
+	selection.Change = 0
+	return selection, nil
+}
+
+// knapsackIterations is how many random subsets Knapsack tries.
+const knapsackIterations = 1000
+
+// Knapsack is the classic bitcoind selection: an exact match, else the
+// smallest coin that pays the target with change, unless a random subset of
+// the smaller coins gets closer.
+func Knapsack(coins []Coin, p Params) (*Selection, error) {
+	pool, total := candidates(coins, p)
+	if total < p.Target {
+		return nil, ErrInsufficientFunds
+	}
+	if p.Target <= 0 {
+		return finish(nil, p)
+	}
+	target := p.withChange()
+	smaller := make([]candidate, 0, len(pool))
+	smallerTotal := int64(0)
+	var lowestLarger *candidate
+	for i := range pool {
+		c := pool[i]
+		if c.effective == p.Target {
+			return finish([]candidate{c}, p)
+		}
+		if c.effective < target {
+			smaller = append(smaller, c)
+			smallerTotal += c.effective
+		} else if lowestLarger == nil || c.effective < lowestLarger.effective {
+			lowestLarger = &pool[i]
+		}
+	}
+	if smallerTotal == p.Target {
+		return finish(smaller, p)
+	}
+	if smallerTotal < target {
+		if lowestLarger != nil {
+			return finish([]candidate{*lowestLarger}, p)
+		}
+		// change is out of reach, pay the target without it
+		return finish(smaller, p)
+	}
+	best, bestValue := approximateBestSubset(smaller, smallerTotal, target)
+	if lowestLarger != nil && bestValue != target && lowestLarger.effective <= bestValue {
+		return finish([]candidate{*lowestLarger}, p)
+	}
+	return finish(best, p)
+}
+
+// approximateBestSubset looks for the subset of pool whose value is the least at or above target.
+func approximateBestSubset(pool []candidate, total int64, target int64) ([]candidate, int64) {
+	bestIncluded := make([]bool, len(pool))
+	for i := range bestIncluded {
+		bestIncluded[i] = true
+	}
+	bestValue := total
+	included := make([]bool, len(pool))
+	for rep := 0; rep < knapsackIterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		value := int64(0)
+		reached := false
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i := range pool {
+				// the first pass includes coins at random, the second tops up with the rest
+				if (pass == 0 && rand.Intn(2) == 0) || (pass == 1 && included[i]) {
+					continue
+				}
+				value += pool[i].effective
+				included[i] = true
+				if value >= target {
+					reached = true
+					if value < bestValue {
+						bestValue = value
+						copy(bestIncluded, included)
+					}
+					value -= pool[i].effective
+					included[i] = false
+				}
+			}
+		}
+	}
+	best := make([]candidate, 0, len(pool))
+	for i, in := range bestIncluded {
+		if in {
+			best = append(best, pool[i])
+		}
+	}
+	return best, bestValue
+}
+
+// LargestFirst spends the largest coins until they pay the target with change.
+func LargestFirst(coins []Coin, p Params) (*Selection, error) {
+	pool, total := candidates(coins, p)
+	if total < p.Target {
+		return nil, ErrInsufficientFunds
+	}
+	target := p.withChange()
+	value := int64(0)
+	selected := make([]candidate, 0, len(pool))
+	for _, c := range pool {
+		if value >= target {
+			break
+		}
+		selected = append(selected, c)
+		value += c.effective
+	}
+	return finish(selected, p)
+}
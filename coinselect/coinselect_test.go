@@ -0,0 +1,118 @@
+package coinselect
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func testCoins(values ...int64) []Coin {
+	coins := make([]Coin, 0, len(values))
+	for i, value := range values {
+		coins = append(coins, Coin{Txid: fmt.Sprintf("%064x", i), Vout: uint32(i), Value: value})
+	}
+	return coins
+}
+
+func testParams(target int64) Params {
+	return Params{
+		Target:      target,
+		FeeRate:     1,
+		InputVSize:  68,
+		ChangeVSize: 31,
+		ChangeCost:  99,
+		MinChange:   546,
+	}
+}
+
+// checkSelection checks selection pays p and accounts for every satoshi.
+func checkSelection(t *testing.T, selection *Selection, p Params) {
+	t.Helper()
+	value := int64(0)
+	for _, coin := range selection.Coins {
+		value += coin.Value
+	}
+	if value != selection.Value {
+		t.Fatalf("selection value %d, coins sum to %d", selection.Value, value)
+	}
+	fee := int64(len(selection.Coins)) * p.InputVSize * p.FeeRate
+	if selection.Change > 0 {
+		fee += p.ChangeVSize * p.FeeRate
+		if selection.Change < p.MinChange {
+			t.Fatalf("change %d below %d", selection.Change, p.MinChange)
+		}
+	}
+	if excess := value - fee - selection.Change - p.Target; excess < 0 {
+		t.Fatalf("selection of %d short by %d", value, -excess)
+	}
+}
+
+func Test_BranchAndBound(t *testing.T) {
+	p := testParams(3000)
+	// 1068 + 2068 pay the target exactly once their input fee is taken
+	selection, err := BranchAndBound(testCoins(5000, 1068, 800, 2068), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSelection(t, selection, p)
+	if len(selection.Coins) != 2 || selection.Value != 3136 || selection.Change != 0 {
+		t.Fatalf("selection: %+v", selection)
+	}
+	if _, err := BranchAndBound(testCoins(5000), p); !errors.Is(err, ErrNoChangelessMatch) {
+		t.Fatalf("expected no changeless match, got %v", err)
+	}
+	if _, err := BranchAndBound(testCoins(1000, 1000), p); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+}
+
+func Test_Knapsack(t *testing.T) {
+	p := testParams(3000)
+	for _, values := range [][]int64{
+		{5000},
+		{1000, 1000, 1000, 1000, 1000},
+		{100000, 700, 900, 1200, 2500},
+		{3068},
+	} {
+		selection, err := Knapsack(testCoins(values...), p)
+		if err != nil {
+			t.Fatalf("%v: %v", values, err)
+		}
+		checkSelection(t, selection, p)
+	}
+	// the smallest coin that leaves change beats the big one
+	selection, err := Knapsack(testCoins(100000, 5000), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection.Value != 5000 {
+		t.Fatalf("selected %d", selection.Value)
+	}
+	if _, err := Knapsack(testCoins(1000, 68), p); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("expected insufficient funds, got %v", err)
+	}
+}
+
+func Test_LargestFirst(t *testing.T) {
+	p := testParams(3000)
+	selection, err := LargestFirst(testCoins(1000, 2000, 1500, 800), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSelection(t, selection, p)
+	if selection.Coins[0].Value != 2000 || selection.Coins[1].Value != 1500 {
+		t.Fatalf("selection: %+v", selection)
+	}
+}
+
+func Test_SelectNegativeTarget(t *testing.T) {
+	// inputs already in the transaction pay for everything
+	p := testParams(-2000)
+	selection, err := Select(testCoins(5000), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selection.Coins) != 0 || selection.Change != 1969 {
+		t.Fatalf("selection: %+v", selection)
+	}
+}
@@ -0,0 +1,194 @@
+package localindex
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BlockSource provides the blocks of the best chain by height.
+type BlockSource interface {
+	// TipHeight returns the height of the best block.
+	TipHeight() (int64, error)
+	Block(height int64) (*wire.MsgBlock, error)
+}
+
+type blockLocation struct {
+	file   string
+	offset int64
+	size   uint32
+}
+
+// FileBlockSource reads blocks from the blk*.dat files of a bitcoind blocks directory.
+// The files store blocks in arrival order, so the best chain is rebuilt from the headers.
+type FileBlockSource struct {
+	key       []byte
+	locations map[chainhash.Hash]*blockLocation
+	chain     []chainhash.Hash
+}
+
+// NewFileBlockSource indexes the block headers found in dir. bitcoind 28 and later
+// obfuscate the files with the key in xor.dat, which is applied when present.
+func NewFileBlockSource(dir string, net *chaincfg.Params) (*FileBlockSource, error) {
+	source := &FileBlockSource{locations: make(map[chainhash.Hash]*blockLocation)}
+	key, err := os.ReadFile(filepath.Join(dir, "xor.dat"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if len(key) > 0 && !bytes.Equal(key, make([]byte, len(key))) {
+		source.key = key
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "blk*.dat"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no block files in %s", dir)
+	}
+	sort.Strings(files)
+	prevs := make(map[chainhash.Hash]chainhash.Hash)
+	for _, file := range files {
+		if err := source.scanFile(file, net, prevs); err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	source.buildChain(net, prevs)
+	return source, nil
+}
+
+func (f *FileBlockSource) open(file string) (*xorFile, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return &xorFile{File: fd, key: f.key}, nil
+}
+
+// scanFile records the location and parent of every block in file.
+func (f *FileBlockSource) scanFile(file string, net *chaincfg.Params, prevs map[chainhash.Hash]chainhash.Hash) error {
+	fd, err := f.open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	offset := int64(0)
+	prefix := make([]byte, 8)
+	header := make([]byte, wire.MaxBlockHeaderPayload)
+	for {
+		if _, err := fd.ReadAt(prefix, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		magic := binary.LittleEndian.Uint32(prefix[:4])
+		if magic == 0 {
+			// the rest of the file is preallocated zeros
+			return nil
+		}
+		if wire.BitcoinNet(magic) != net.Net {
+			return fmt.Errorf("unexpected magic %x at %d", magic, offset)
+		}
+		size := binary.LittleEndian.Uint32(prefix[4:])
+		if _, err := fd.ReadAt(header, offset+8); err != nil {
+			return err
+		}
+		blockHeader := &wire.BlockHeader{}
+		if err := blockHeader.Deserialize(bytes.NewReader(header)); err != nil {
+			return err
+		}
+		hash := blockHeader.BlockHash()
+		f.locations[hash] = &blockLocation{file: file, offset: offset + 8, size: size}
+		prevs[hash] = blockHeader.PrevBlock
+		offset += 8 + int64(size)
+	}
+}
+
+// buildChain picks the longest chain from the genesis block through the scanned headers.
+func (f *FileBlockSource) buildChain(net *chaincfg.Params, prevs map[chainhash.Hash]chainhash.Hash) {
+	heights := map[chainhash.Hash]int64{*net.GenesisHash: 0}
+	var tip chainhash.Hash = *net.GenesisHash
+	for hash := range prevs {
+		path := make([]chainhash.Hash, 0)
+		current := hash
+		height, known := heights[current]
+		for !known {
+			prev, ok := prevs[current]
+			if !ok {
+				break
+			}
+			path = append(path, current)
+			current = prev
+			height, known = heights[current]
+		}
+		if !known {
+			// an orphan that does not connect to genesis
+			continue
+		}
+		for i := len(path) - 1; i >= 0; i-- {
+			height++
+			heights[path[i]] = height
+		}
+		if height > heights[tip] {
+			tip = hash
+		}
+	}
+	f.chain = make([]chainhash.Hash, heights[tip]+1)
+	for current := tip; ; current = prevs[current] {
+		f.chain[heights[current]] = current
+		if current == *net.GenesisHash {
+			break
+		}
+	}
+}
+
+func (f *FileBlockSource) TipHeight() (int64, error) {
+	return int64(len(f.chain) - 1), nil
+}
+
+func (f *FileBlockSource) Block(height int64) (*wire.MsgBlock, error) {
+	if height < 0 || height >= int64(len(f.chain)) {
+		return nil, fmt.Errorf("no block at height %d", height)
+	}
+	location, ok := f.locations[f.chain[height]]
+	if !ok {
+		return nil, fmt.Errorf("block %s is not in the block files", f.chain[height])
+	}
+	fd, err := f.open(location.file)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	data := make([]byte, location.size)
+	if _, err := fd.ReadAt(data, location.offset); err != nil {
+		return nil, err
+	}
+	block := &wire.MsgBlock{}
+	err = block.Deserialize(bytes.NewReader(data))
+	return block, err
+}
+
+// xorFile is a block file deobfuscated with the xor key of the blocks directory.
+type xorFile struct {
+	*os.File
+	key []byte
+}
+
+func (x *xorFile) ReadAt(p []byte, offset int64) (int, error) {
+	n, err := x.File.ReadAt(p, offset)
+	if len(x.key) > 0 {
+		for i := 0; i < n; i++ {
+			p[i] ^= x.key[(offset+int64(i))%int64(len(x.key))]
+		}
+	}
+	return n, err
+}
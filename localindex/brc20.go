@@ -0,0 +1,238 @@
+package localindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const maxDecimals = 18
+
+// brc20Op is the json body of a brc20 inscription, every field must be a string.
+type brc20Op struct {
+	P        string
+	Op       string
+	Tick     string
+	Amt      string
+	Max      string
+	Lim      string
+	Dec      string
+	SelfMint string
+}
+
+// parseBRC20 parses a brc20 inscription body, ok is false for anything that is not one.
+func parseBRC20(contentType string, body []byte) (*brc20Op, bool) {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.TrimSpace(mediaType)
+	if mediaType != "text/plain" && mediaType != "application/json" {
+		return nil, false
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+	strs := make(map[string]string, len(fields))
+	for key, value := range fields {
+		str, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		strs[key] = str
+	}
+	op := &brc20Op{
+		P:        strs["p"],
+		Op:       strs["op"],
+		Tick:     strs["tick"],
+		Amt:      strs["amt"],
+		Max:      strs["max"],
+		Lim:      strs["lim"],
+		Dec:      strs["dec"],
+		SelfMint: strs["self_mint"],
+	}
+	if op.P != "brc-20" {
+		return nil, false
+	}
+	if len(op.Tick) != 4 && len(op.Tick) != 5 {
+		return nil, false
+	}
+	op.Tick = strings.ToLower(op.Tick)
+	return op, true
+}
+
+// parseAmount parses a brc20 decimal amount into units of 10^-decimals.
+func parseAmount(amount string, decimals int) (*big.Int, error) {
+	integer, fraction, hasPoint := strings.Cut(amount, ".")
+	if integer == "" || (hasPoint && fraction == "") || len(fraction) > decimals {
+		return nil, fmt.Errorf("invalid amount: %q", amount)
+	}
+	for _, c := range integer + fraction {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid amount: %q", amount)
+		}
+	}
+	fraction += strings.Repeat("0", decimals-len(fraction))
+	value, ok := new(big.Int).SetString(integer+fraction, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount: %q", amount)
+	}
+	return value, nil
+}
+
+// maxSupply is the largest amount a ticker with decimals can hold, uint64 max whole tokens.
+func maxSupply(decimals int) *big.Int {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	return new(big.Int).Mul(new(big.Int).SetUint64(math.MaxUint64), scale)
+}
+
+func formatAmount(amount *big.Int, decimals int) string {
+	digits := amount.String()
+	if decimals == 0 {
+		return digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	integer := digits[:len(digits)-decimals]
+	fraction := strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return integer
+	}
+	return integer + "." + fraction
+}
+
+// applyInscribe applies the brc20 operation of a newly created inscription owned by owner.
+func (s *Store) applyInscribe(inscription *Inscription, envelope *Envelope, owner string, height int64) {
+	op, ok := parseBRC20(envelope.ContentType, envelope.Body)
+	if !ok {
+		return
+	}
+	switch op.Op {
+	case "deploy":
+		s.applyDeploy(inscription, op, height)
+	case "mint":
+		s.applyMint(op, envelope.Parent, owner)
+	case "transfer":
+		s.applyInscribeTransfer(inscription, op, owner, height)
+	}
+}
+
+func (s *Store) applyDeploy(inscription *Inscription, op *brc20Op, height int64) {
+	if _, ok := s.Tickers[op.Tick]; ok {
+		return
+	}
+	// only 5 byte tickers can be self minted, and they must be
+	selfMint := len(op.Tick) == 5 && op.SelfMint == "true"
+	if len(op.Tick) == 5 && !selfMint {
+		return
+	}
+	decimals := maxDecimals
+	if op.Dec != "" {
+		dec, err := strconv.Atoi(op.Dec)
+		if err != nil || dec < 0 || dec > maxDecimals || strconv.Itoa(dec) != op.Dec {
+			return
+		}
+		decimals = dec
+	}
+	supply, err := parseAmount(op.Max, decimals)
+	if err != nil || supply.Cmp(maxSupply(decimals)) > 0 {
+		return
+	}
+	// a self mint ticker may deploy with max 0 meaning unlimited supply
+	if supply.Sign() == 0 {
+		if !selfMint {
+			return
+		}
+		supply = maxSupply(decimals)
+	}
+	limit := supply
+	if op.Lim != "" {
+		limit, err = parseAmount(op.Lim, decimals)
+		if err != nil || limit.Cmp(maxSupply(decimals)) > 0 {
+			return
+		}
+		if limit.Sign() == 0 {
+			if !selfMint {
+				return
+			}
+			limit = maxSupply(decimals)
+		}
+	}
+	s.Tickers[op.Tick] = &Ticker{
+		Tick:          op.Tick,
+		InscriptionID: inscription.ID,
+		Max:           supply,
+		Limit:         limit,
+		Minted:        new(big.Int),
+		Decimals:      decimals,
+		SelfMint:      selfMint,
+		Height:        height,
+	}
+}
+
+func (s *Store) applyMint(op *brc20Op, parent string, owner string) {
+	ticker, ok := s.Tickers[op.Tick]
+	if !ok || owner == "" {
+		return
+	}
+	if ticker.SelfMint && parent != ticker.InscriptionID {
+		return
+	}
+	amount, err := parseAmount(op.Amt, ticker.Decimals)
+	if err != nil || amount.Sign() <= 0 || amount.Cmp(ticker.Limit) > 0 {
+		return
+	}
+	remaining := new(big.Int).Sub(ticker.Max, ticker.Minted)
+	if remaining.Sign() <= 0 {
+		return
+	}
+	if amount.Cmp(remaining) > 0 {
+		amount = remaining
+	}
+	ticker.Minted.Add(ticker.Minted, amount)
+	balance := s.balance(owner, op.Tick)
+	balance.Available.Add(balance.Available, amount)
+}
+
+func (s *Store) applyInscribeTransfer(inscription *Inscription, op *brc20Op, owner string, height int64) {
+	ticker, ok := s.Tickers[op.Tick]
+	if !ok || owner == "" {
+		return
+	}
+	amount, err := parseAmount(op.Amt, ticker.Decimals)
+	if err != nil || amount.Sign() <= 0 || amount.Cmp(ticker.Max) > 0 {
+		return
+	}
+	balance := s.balance(owner, op.Tick)
+	if balance.Available.Cmp(amount) < 0 {
+		return
+	}
+	balance.Available.Sub(balance.Available, amount)
+	balance.Transferable.Add(balance.Transferable, amount)
+	s.Transfers[inscription.ID] = &Transfer{
+		InscriptionID: inscription.ID,
+		Tick:          op.Tick,
+		Amount:        amount,
+		From:          owner,
+		Height:        height,
+	}
+}
+
+// applyTransfer settles a transfer inscription the first time it moves, to is empty when
+// it went to fees and the amount returns to the sender.
+func (s *Store) applyTransfer(inscriptionID string, to string) {
+	transfer, ok := s.Transfers[inscriptionID]
+	if !ok {
+		return
+	}
+	delete(s.Transfers, inscriptionID)
+	from := s.balance(transfer.From, transfer.Tick)
+	from.Transferable.Sub(from.Transferable, transfer.Amount)
+	if to == "" {
+		to = transfer.From
+	}
+	receiver := s.balance(to, transfer.Tick)
+	receiver.Available.Add(receiver.Available, transfer.Amount)
+}
@@ -0,0 +1,180 @@
+package localindex
+
+import (
+	"bytes"
+
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ord envelope field tags, see https://docs.ordinals.com/inscriptions.html
+var (
+	tagContentType     = []byte{1}
+	tagPointer         = []byte{2}
+	tagParent          = []byte{3}
+	tagContentEncoding = []byte{9}
+)
+
+// Envelope is an inscription revealed by a tapscript in a transaction input.
+type Envelope struct {
+	Input           int
+	ContentType     string
+	ContentEncoding string
+	Body            []byte
+	// Pointer is the output offset the inscription asks to be placed on, if any.
+	Pointer *uint64
+	// Parent is the inscription id of the parent, if any.
+	Parent string
+}
+
+// ParseEnvelopes returns the ord envelopes revealed by tx in input order.
+func ParseEnvelopes(tx *wire.MsgTx) []*Envelope {
+	envelopes := make([]*Envelope, 0)
+	for i, txIn := range tx.TxIn {
+		script := tapscript(txIn.Witness)
+		if script == nil {
+			continue
+		}
+		for _, pushes := range envelopePushes(script) {
+			envelopes = append(envelopes, newEnvelope(i, pushes))
+		}
+	}
+	return envelopes
+}
+
+// tapscript returns the leaf script of a script path spend witness, nil for other witnesses.
+func tapscript(witness wire.TxWitness) []byte {
+	n := len(witness)
+	if n > 0 && len(witness[n-1]) > 0 && witness[n-1][0] == txscript.TaprootAnnexTag {
+		n--
+	}
+	if n < 2 {
+		return nil
+	}
+	return witness[n-2]
+}
+
+// envelopePushes returns the data pushes of every OP_FALSE OP_IF "ord" ... OP_ENDIF envelope in script.
+func envelopePushes(script []byte) [][][]byte {
+	result := make([][][]byte, 0)
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	// state counts the OP_FALSE OP_IF "ord" prefix matched so far
+	state := 0
+	var pushes [][]byte
+	for tokenizer.Next() {
+		op := tokenizer.Opcode()
+		if state == 3 {
+			if op == txscript.OP_ENDIF {
+				result = append(result, pushes)
+				state = 0
+				continue
+			}
+			data, ok := pushData(op, tokenizer.Data())
+			if !ok {
+				state = 0
+				continue
+			}
+			pushes = append(pushes, data)
+			continue
+		}
+		switch {
+		case state == 0 && op == txscript.OP_FALSE:
+			state = 1
+		case state == 1 && op == txscript.OP_IF:
+			state = 2
+		case state == 2 && bytes.Equal(tokenizer.Data(), []byte("ord")):
+			state = 3
+			pushes = make([][]byte, 0)
+		case op == txscript.OP_FALSE:
+			state = 1
+		default:
+			state = 0
+		}
+	}
+	return result
+}
+
+// pushData returns the bytes op pushes, ok is false when op is not a push.
+func pushData(op byte, data []byte) ([]byte, bool) {
+	switch {
+	case op == txscript.OP_0:
+		return []byte{}, true
+	case op <= txscript.OP_PUSHDATA4:
+		return data, true
+	case op == txscript.OP_1NEGATE:
+		return []byte{0x81}, true
+	case op >= txscript.OP_1 && op <= txscript.OP_16:
+		return []byte{op - txscript.OP_1 + 1}, true
+	default:
+		return nil, false
+	}
+}
+
+func newEnvelope(input int, pushes [][]byte) *Envelope {
+	envelope := &Envelope{Input: input}
+	seen := make(map[string]bool)
+	for i := 0; i < len(pushes); i += 2 {
+		tag := pushes[i]
+		if len(tag) == 0 {
+			for _, chunk := range pushes[i+1:] {
+				envelope.Body = append(envelope.Body, chunk...)
+			}
+			if envelope.Body == nil {
+				envelope.Body = []byte{}
+			}
+			break
+		}
+		if i+1 >= len(pushes) || seen[string(tag)] {
+			continue
+		}
+		seen[string(tag)] = true
+		value := pushes[i+1]
+		switch {
+		case bytes.Equal(tag, tagContentType):
+			envelope.ContentType = string(value)
+		case bytes.Equal(tag, tagContentEncoding):
+			envelope.ContentEncoding = string(value)
+		case bytes.Equal(tag, tagPointer):
+			if pointer, ok := littleEndian(value); ok {
+				envelope.Pointer = &pointer
+			}
+		case bytes.Equal(tag, tagParent):
+			if parent, ok := inscriptionIdFromBytes(value); ok {
+				envelope.Parent = parent
+			}
+		}
+	}
+	return envelope
+}
+
+// littleEndian decodes a little endian integer with no significant bytes past the eighth.
+func littleEndian(value []byte) (uint64, bool) {
+	result := uint64(0)
+	for i, b := range value {
+		if i >= 8 {
+			if b != 0 {
+				return 0, false
+			}
+			continue
+		}
+		result |= uint64(b) << (8 * i)
+	}
+	return result, true
+}
+
+// inscriptionIdFromBytes decodes the binary inscription id used by the parent field,
+// the txid followed by the little endian index with trailing zeros trimmed.
+func inscriptionIdFromBytes(value []byte) (string, bool) {
+	if len(value) < chainhash.HashSize || len(value) > chainhash.HashSize+4 {
+		return "", false
+	}
+	hash, err := chainhash.NewHash(value[:chainhash.HashSize])
+	if err != nil {
+		return "", false
+	}
+	index, _ := littleEndian(value[chainhash.HashSize:])
+	return ordinal.InscriptionID{Txid: *hash, Index: uint32(index)}.String(), true
+}
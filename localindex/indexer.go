@@ -0,0 +1,253 @@
+package localindex
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"brc20tools/ordinal"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// StartHeights are the heights of the first inscription on each network, nothing
+// before them needs indexing.
+var StartHeights = map[string]int64{
+	"mainnet":  767430,
+	"testnet3": 2413343,
+	"signet":   112402,
+	"regtest":  0,
+}
+
+// recentBlocks is how many blocks of outputs are kept in memory to value inputs
+// without asking the PrevoutFetcher.
+const recentBlocks = 6
+
+// PrevoutFetcher returns the output spent by an input the indexer has not seen.
+type PrevoutFetcher func(outpoint wire.OutPoint) (*wire.TxOut, error)
+
+// Indexer applies blocks to a Store. It follows each inscribed sat through spends
+// by first-in-first-out ordinal theory and applies the brc20 deploy, mint and
+// transfer rules. Inscriptions sent to fees are marked lost instead of being
+// followed into the coinbase, and cursed inscriptions are not told apart.
+type Indexer struct {
+	Store    *Store
+	net      *chaincfg.Params
+	prevouts PrevoutFetcher
+	recent   map[wire.OutPoint]int64
+	created  map[int64][]wire.OutPoint
+}
+
+func NewIndexer(store *Store, net *chaincfg.Params, prevouts PrevoutFetcher) *Indexer {
+	return &Indexer{
+		Store:    store,
+		net:      net,
+		prevouts: prevouts,
+		recent:   make(map[wire.OutPoint]int64),
+		created:  make(map[int64][]wire.OutPoint),
+	}
+}
+
+// Sync indexes source from the block after the store height up to its tip. The
+// store is saved every saveEvery blocks and at the end, progress is called after
+// each block when not nil.
+func (ix *Indexer) Sync(source BlockSource, saveEvery int64, progress func(height int64)) error {
+	tip, err := source.TipHeight()
+	if err != nil {
+		return err
+	}
+	for height := ix.Store.Height + 1; height <= tip; height++ {
+		block, err := source.Block(height)
+		if err != nil {
+			return err
+		}
+		if err := ix.IndexBlock(height, block); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(height)
+		}
+		if saveEvery > 0 && height%saveEvery == 0 {
+			if err := ix.Store.Save(); err != nil {
+				return err
+			}
+		}
+	}
+	return ix.Store.Save()
+}
+
+// IndexBlock applies the block at height, which must extend the last indexed block.
+func (ix *Indexer) IndexBlock(height int64, block *wire.MsgBlock) error {
+	if height != ix.Store.Height+1 {
+		return fmt.Errorf("expected block %d, got %d", ix.Store.Height+1, height)
+	}
+	if ix.Store.BlockHash != "" && block.Header.PrevBlock.String() != ix.Store.BlockHash {
+		return fmt.Errorf("block %d does not extend %s, the chain reorganized and the index must be rebuilt", height, ix.Store.BlockHash)
+	}
+	for _, tx := range block.Transactions {
+		if err := ix.indexTx(height, tx); err != nil {
+			return fmt.Errorf("tx %s: %w", tx.TxHash(), err)
+		}
+	}
+	for _, outpoint := range ix.created[height-recentBlocks] {
+		delete(ix.recent, outpoint)
+	}
+	delete(ix.created, height-recentBlocks)
+	ix.Store.Height = height
+	ix.Store.BlockHash = block.BlockHash().String()
+	return nil
+}
+
+// flotsam is an inscription moving through a transaction, offset counts sats from the first input.
+type flotsam struct {
+	id       string
+	envelope *Envelope
+	offset   uint64
+}
+
+func (ix *Indexer) indexTx(height int64, tx *wire.MsgTx) error {
+	defer ix.remember(height, tx)
+	if blockchain.IsCoinBaseTx(tx) {
+		return nil
+	}
+	envelopes := ParseEnvelopes(tx)
+	inscribed := len(envelopes) > 0
+	for _, txIn := range tx.TxIn {
+		if _, ok := ix.Store.Outputs[txIn.PreviousOutPoint.String()]; ok {
+			inscribed = true
+		}
+	}
+	if !inscribed {
+		for _, txIn := range tx.TxIn {
+			delete(ix.recent, txIn.PreviousOutPoint)
+		}
+		return nil
+	}
+
+	txid := tx.TxHash()
+	floating := make([]*flotsam, 0)
+	inputOffset := uint64(0)
+	next := 0
+	for i, txIn := range tx.TxIn {
+		key := txIn.PreviousOutPoint.String()
+		var value int64
+		if output, ok := ix.Store.Outputs[key]; ok {
+			for _, id := range output.Inscriptions {
+				floating = append(floating, &flotsam{id: id, offset: inputOffset + ix.Store.Inscriptions[id].Offset})
+			}
+			value = output.Value
+			delete(ix.Store.Outputs, key)
+		} else {
+			var err error
+			value, err = ix.prevoutValue(txIn.PreviousOutPoint)
+			if err != nil {
+				return err
+			}
+		}
+		for ; next < len(envelopes) && envelopes[next].Input == i; next++ {
+			floating = append(floating, &flotsam{
+				id:       ordinal.InscriptionID{Txid: txid, Index: uint32(next)}.String(),
+				envelope: envelopes[next],
+				offset:   inputOffset,
+			})
+		}
+		delete(ix.recent, txIn.PreviousOutPoint)
+		inputOffset += uint64(value)
+	}
+
+	totalOutput := uint64(0)
+	for _, txOut := range tx.TxOut {
+		totalOutput += uint64(txOut.Value)
+	}
+	for _, f := range floating {
+		if f.envelope != nil && f.envelope.Pointer != nil && *f.envelope.Pointer < totalOutput {
+			f.offset = *f.envelope.Pointer
+		}
+		if f.envelope != nil {
+			ix.Store.Inscriptions[f.id] = &Inscription{
+				ID:          f.id,
+				Number:      ix.Store.Number,
+				Height:      height,
+				ContentType: f.envelope.ContentType,
+			}
+			ix.Store.Number++
+		}
+		inscription := ix.Store.Inscriptions[f.id]
+		owner := ""
+		vout, offset, ok := locate(tx, f.offset)
+		if ok {
+			txOut := tx.TxOut[vout]
+			owner = ix.address(txOut.PkScript)
+			outpoint := ordinal.Outpoint{Txid: txid, Vout: vout}.String()
+			output, exists := ix.Store.Outputs[outpoint]
+			if !exists {
+				output = &Output{Value: txOut.Value, Address: owner}
+				ix.Store.Outputs[outpoint] = output
+			}
+			output.Inscriptions = append(output.Inscriptions, f.id)
+			inscription.Outpoint = outpoint
+			inscription.Offset = offset
+			inscription.Address = owner
+			inscription.Value = txOut.Value
+		} else {
+			inscription.Outpoint = ""
+			inscription.Offset = 0
+			inscription.Address = ""
+			inscription.Value = 0
+		}
+		if f.envelope != nil {
+			ix.Store.applyInscribe(inscription, f.envelope, owner, height)
+		} else {
+			ix.Store.applyTransfer(f.id, owner)
+		}
+	}
+	return nil
+}
+
+// locate finds the output holding the sat at offset of the outputs of tx, ok is
+// false when the sat goes to fees.
+func locate(tx *wire.MsgTx, offset uint64) (uint32, uint64, bool) {
+	start := uint64(0)
+	for vout, txOut := range tx.TxOut {
+		end := start + uint64(txOut.Value)
+		if offset < end {
+			return uint32(vout), offset - start, true
+		}
+		start = end
+	}
+	return 0, 0, false
+}
+
+func (ix *Indexer) remember(height int64, tx *wire.MsgTx) {
+	txid := tx.TxHash()
+	for vout, txOut := range tx.TxOut {
+		outpoint := *wire.NewOutPoint(&txid, uint32(vout))
+		ix.recent[outpoint] = txOut.Value
+		ix.created[height] = append(ix.created[height], outpoint)
+	}
+}
+
+func (ix *Indexer) prevoutValue(outpoint wire.OutPoint) (int64, error) {
+	if value, ok := ix.recent[outpoint]; ok {
+		return value, nil
+	}
+	if ix.prevouts == nil {
+		return 0, fmt.Errorf("unknown prevout %s", outpoint)
+	}
+	txOut, err := ix.prevouts(outpoint)
+	if err != nil {
+		return 0, err
+	}
+	return txOut.Value, nil
+}
+
+// address returns the address paid by pkScript, or the script hex when it has none.
+func (ix *Indexer) address(pkScript []byte) string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, ix.net)
+	if err != nil || len(addrs) != 1 {
+		return hex.EncodeToString(pkScript)
+	}
+	return addrs[0].EncodeAddress()
+}
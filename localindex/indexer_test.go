@@ -0,0 +1,243 @@
+package localindex
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var testNet = &chaincfg.RegressionNetParams
+
+type testChain struct {
+	t        *testing.T
+	indexer  *Indexer
+	prevouts map[wire.OutPoint]*wire.TxOut
+	height   int64
+	prevHash chainhash.Hash
+	funded   int
+}
+
+func newTestChain(t *testing.T) *testChain {
+	store, err := OpenStore(filepath.Join(t.TempDir(), "index.json"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := &testChain{t: t, prevouts: make(map[wire.OutPoint]*wire.TxOut)}
+	chain.indexer = NewIndexer(store, testNet, func(outpoint wire.OutPoint) (*wire.TxOut, error) {
+		txOut, ok := chain.prevouts[outpoint]
+		if !ok {
+			return nil, fmt.Errorf("unknown prevout %s", outpoint)
+		}
+		return txOut, nil
+	})
+	return chain
+}
+
+// fundingOutpoint returns a fake outpoint of value known only to the prevout fetcher.
+func (c *testChain) fundingOutpoint(value int64) wire.OutPoint {
+	c.funded++
+	hash := chainhash.HashH([]byte(fmt.Sprintf("funding-%d", c.funded)))
+	outpoint := *wire.NewOutPoint(&hash, 0)
+	c.prevouts[outpoint] = wire.NewTxOut(value, nil)
+	return outpoint
+}
+
+func (c *testChain) mine(txs ...*wire.MsgTx) {
+	coinbase := wire.NewMsgTx(2)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, math.MaxUint32), []byte{byte(c.height), 0}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(50*btcutil.SatoshiPerBitcoin, []byte{txscript.OP_TRUE}))
+	block := &wire.MsgBlock{
+		Header:       wire.BlockHeader{Version: 4, PrevBlock: c.prevHash},
+		Transactions: append([]*wire.MsgTx{coinbase}, txs...),
+	}
+	if err := c.indexer.IndexBlock(c.height, block); err != nil {
+		c.t.Fatal(err)
+	}
+	c.height++
+	c.prevHash = block.BlockHash()
+}
+
+func newTestAddress(t *testing.T) (string, []byte) {
+	privkey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(privkey.PubKey().SerializeCompressed()), testNet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addr.EncodeAddress(), pkScript
+}
+
+func envelopeScript(t *testing.T, fields [][2][]byte, body []byte) []byte {
+	builder := txscript.NewScriptBuilder().
+		AddData(make([]byte, 32)).
+		AddOp(txscript.OP_CHECKSIG).
+		AddOp(txscript.OP_FALSE).
+		AddOp(txscript.OP_IF).
+		AddData([]byte("ord"))
+	for _, field := range fields {
+		builder.AddData(field[0]).AddData(field[1])
+	}
+	builder.AddOp(txscript.OP_0)
+	for i := 0; i < len(body); i += txscript.MaxScriptElementSize {
+		builder.AddFullData(body[i:min(i+txscript.MaxScriptElementSize, len(body))])
+	}
+	builder.AddOp(txscript.OP_ENDIF)
+	script, err := builder.Script()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return script
+}
+
+// reveal inscribes body from a fresh funding output into a postage output paying pkScript.
+func (c *testChain) reveal(body string, pkScript []byte, extra ...[2][]byte) *wire.MsgTx {
+	fields := append([][2][]byte{{tagContentType, []byte("text/plain;charset=utf-8")}}, extra...)
+	script := envelopeScript(c.t, fields, []byte(body))
+	outpoint := c.fundingOutpoint(1000)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&outpoint, nil, wire.TxWitness{make([]byte, 64), script, make([]byte, 33)}))
+	tx.AddTxOut(wire.NewTxOut(546, pkScript))
+	return tx
+}
+
+// send spends output 0 of prev, with a leading fee input, into outputs.
+func (c *testChain) send(prev *wire.MsgTx, outputs ...*wire.TxOut) *wire.MsgTx {
+	feeInput := c.fundingOutpoint(10000)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(ptr(prev.TxHash()), 0), nil, nil))
+	tx.AddTxIn(wire.NewTxIn(&feeInput, nil, nil))
+	for _, txOut := range outputs {
+		tx.AddTxOut(txOut)
+	}
+	return tx
+}
+
+func ptr(hash chainhash.Hash) *chainhash.Hash {
+	return &hash
+}
+
+func (c *testChain) expectBalance(address string, available string, transferable string) {
+	c.t.Helper()
+	gotAvailable, gotTransferable := c.indexer.Store.Balance(address, "TEST")
+	if gotAvailable != available || gotTransferable != transferable {
+		c.t.Fatalf("balance of %s: %s/%s, want %s/%s", address, gotAvailable, gotTransferable, available, transferable)
+	}
+}
+
+func Test_IndexBRC20(t *testing.T) {
+	chain := newTestChain(t)
+	alice, alicePkScript := newTestAddress(t)
+	bob, bobPkScript := newTestAddress(t)
+
+	deploy := chain.reveal(`{"p":"brc-20","op":"deploy","tick":"TEST","max":"1000","lim":"100","dec":"2"}`, alicePkScript)
+	chain.mine(deploy)
+	ticker := chain.indexer.Store.Ticker("test")
+	if ticker == nil || ticker.InscriptionID != fmt.Sprintf("%si0", deploy.TxHash()) {
+		t.Fatalf("ticker not deployed: %+v", ticker)
+	}
+
+	chain.mine(
+		chain.reveal(`{"p":"brc-20","op":"mint","tick":"test","amt":"100"}`, alicePkScript),
+		chain.reveal(`{"p":"brc-20","op":"mint","tick":"test","amt":"100.001"}`, alicePkScript),
+		chain.reveal(`{"p":"brc-20","op":"mint","tick":"test","amt":"200"}`, alicePkScript),
+		chain.reveal(`{"p":"brc-20","op":"mint","tick":"test","amt":50}`, alicePkScript),
+	)
+	chain.expectBalance(alice, "100", "0")
+
+	transfer := chain.reveal(`{"p":"brc-20","op":"transfer","tick":"test","amt":"60.5"}`, alicePkScript)
+	chain.mine(transfer, chain.reveal(`{"p":"brc-20","op":"transfer","tick":"test","amt":"40"}`, alicePkScript))
+	chain.expectBalance(alice, "39.5", "60.5")
+	transfers := chain.indexer.Store.TransferableInscriptions(alice, "test")
+	if len(transfers) != 1 || transfers[0].InscriptionID != fmt.Sprintf("%si0", transfer.TxHash()) {
+		t.Fatalf("transferable inscriptions: %+v", transfers)
+	}
+
+	// the transfer inscription sits on the first sat of the first input so the first output receives it
+	send := chain.send(transfer, wire.NewTxOut(546, bobPkScript), wire.NewTxOut(9000, alicePkScript))
+	chain.mine(send)
+	chain.expectBalance(alice, "39.5", "0")
+	chain.expectBalance(bob, "60.5", "0")
+	inscription := chain.indexer.Store.Inscription(fmt.Sprintf("%si0", transfer.TxHash()))
+	if inscription.Location() != fmt.Sprintf("%s:0:0", send.TxHash()) || inscription.Address != bob {
+		t.Fatalf("inscription location: %s %s", inscription.Location(), inscription.Address)
+	}
+	if got := chain.indexer.Store.InscriptionsOn(*wire.NewOutPoint(ptr(send.TxHash()), 0)); len(got) != 1 {
+		t.Fatalf("inscriptions on output: %d", len(got))
+	}
+
+	// a transfer sent as fee returns to the sender
+	lost := chain.reveal(`{"p":"brc-20","op":"transfer","tick":"test","amt":"10"}`, bobPkScript)
+	chain.mine(lost)
+	chain.expectBalance(bob, "50.5", "10")
+	feeInput := chain.fundingOutpoint(10000)
+	burn := wire.NewMsgTx(2)
+	burn.AddTxIn(wire.NewTxIn(&feeInput, nil, nil))
+	burn.AddTxIn(wire.NewTxIn(wire.NewOutPoint(ptr(lost.TxHash()), 0), nil, nil))
+	burn.AddTxOut(wire.NewTxOut(5000, alicePkScript))
+	chain.mine(burn)
+	chain.expectBalance(bob, "60.5", "0")
+	chain.expectBalance(alice, "39.5", "0")
+
+	if err := chain.indexer.Store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := OpenStore(chain.indexer.Store.path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if available, _ := reopened.Balance(bob, "test"); available != "60.5" || reopened.Height != chain.height-1 {
+		t.Fatalf("reopened store: %s at %d", available, reopened.Height)
+	}
+}
+
+func Test_IndexPointer(t *testing.T) {
+	chain := newTestChain(t)
+	_, pkScript := newTestAddress(t)
+	pointer := [2][]byte{tagPointer, {0x22, 0x02}}
+	reveal := chain.reveal("hello", pkScript, pointer)
+	reveal.TxOut[0].Value = 1000
+	reveal.AddTxOut(wire.NewTxOut(546, pkScript))
+	chain.mine(reveal)
+	inscription := chain.indexer.Store.Inscription(fmt.Sprintf("%si0", reveal.TxHash()))
+	if inscription.Location() != fmt.Sprintf("%s:0:546", reveal.TxHash()) {
+		t.Fatalf("pointer location: %s", inscription.Location())
+	}
+}
+
+func Test_ParseEnvelopes(t *testing.T) {
+	body := make([]byte, 1200)
+	for i := range body {
+		body[i] = byte(i)
+	}
+	parent := make([]byte, 33)
+	parent[32] = 1
+	script := envelopeScript(t, [][2][]byte{{tagContentType, []byte("image/png")}, {tagParent, parent}}, body)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, wire.TxWitness{make([]byte, 64), make([]byte, 33)}))
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil, wire.TxWitness{make([]byte, 64), script, make([]byte, 33)}))
+	envelopes := ParseEnvelopes(tx)
+	if len(envelopes) != 1 {
+		t.Fatalf("envelopes: %d", len(envelopes))
+	}
+	envelope := envelopes[0]
+	if envelope.Input != 1 || envelope.ContentType != "image/png" || string(envelope.Body) != string(body) {
+		t.Fatalf("envelope: %d %s %d bytes", envelope.Input, envelope.ContentType, len(envelope.Body))
+	}
+	if envelope.Parent != fmt.Sprintf("%si1", chainhash.Hash{}) {
+		t.Fatalf("parent: %s", envelope.Parent)
+	}
+}
@@ -0,0 +1,187 @@
+package localindex
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Inscription is an indexed inscription and where it currently sits.
+type Inscription struct {
+	ID          string `json:"id"`
+	Number      int64  `json:"number"`
+	Height      int64  `json:"height"`
+	ContentType string `json:"content_type"`
+	// Outpoint and Offset locate the inscribed sat, Outpoint is empty once the sat is lost to fees.
+	Outpoint string `json:"outpoint"`
+	Offset   uint64 `json:"offset"`
+	Address  string `json:"address"`
+	Value    int64  `json:"value"`
+}
+
+// Location returns the satpoint of the inscription, txid:vout:offset.
+func (i *Inscription) Location() string {
+	if i.Outpoint == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", i.Outpoint, i.Offset)
+}
+
+// Output is an unspent output carrying inscriptions.
+type Output struct {
+	Value        int64    `json:"value"`
+	Address      string   `json:"address"`
+	Inscriptions []string `json:"inscriptions"`
+}
+
+// Ticker is a deployed brc20 ticker, amounts are in units of 10^-Decimals.
+type Ticker struct {
+	Tick          string   `json:"tick"`
+	InscriptionID string   `json:"inscription_id"`
+	Max           *big.Int `json:"max"`
+	Limit         *big.Int `json:"limit"`
+	Minted        *big.Int `json:"minted"`
+	Decimals      int      `json:"decimals"`
+	SelfMint      bool     `json:"self_mint"`
+	Height        int64    `json:"height"`
+}
+
+// Balance is a brc20 balance of one address, in units of the ticker.
+type Balance struct {
+	Available    *big.Int `json:"available"`
+	Transferable *big.Int `json:"transferable"`
+}
+
+// Transfer is an inscribed transfer that has not been sent yet.
+type Transfer struct {
+	InscriptionID string   `json:"inscription_id"`
+	Tick          string   `json:"tick"`
+	Amount        *big.Int `json:"amount"`
+	From          string   `json:"from"`
+	Height        int64    `json:"height"`
+}
+
+// Store is the local index state, persisted as a json file.
+type Store struct {
+	path string
+
+	Height       int64                          `json:"height"`
+	BlockHash    string                         `json:"block_hash"`
+	Number       int64                          `json:"number"`
+	Inscriptions map[string]*Inscription        `json:"inscriptions"`
+	Outputs      map[string]*Output             `json:"outputs"`
+	Tickers      map[string]*Ticker             `json:"tickers"`
+	Balances     map[string]map[string]*Balance `json:"balances"`
+	Transfers    map[string]*Transfer           `json:"transfers"`
+}
+
+// OpenStore loads the store at path, an empty store indexing from startHeight when it does not exist.
+func OpenStore(path string, startHeight int64) (*Store, error) {
+	store := &Store{
+		path:         path,
+		Height:       startHeight - 1,
+		Inscriptions: make(map[string]*Inscription),
+		Outputs:      make(map[string]*Output),
+		Tickers:      make(map[string]*Ticker),
+		Balances:     make(map[string]map[string]*Balance),
+		Transfers:    make(map[string]*Transfer),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return store, nil
+}
+
+// Save writes the store to its file, replacing it atomically.
+func (s *Store) Save() error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func (s *Store) balance(address string, tick string) *Balance {
+	balances, ok := s.Balances[address]
+	if !ok {
+		balances = make(map[string]*Balance)
+		s.Balances[address] = balances
+	}
+	balance, ok := balances[tick]
+	if !ok {
+		balance = &Balance{Available: new(big.Int), Transferable: new(big.Int)}
+		balances[tick] = balance
+	}
+	return balance
+}
+
+// Balance returns the available and transferable balance of address in tick as decimal strings.
+func (s *Store) Balance(address string, tick string) (available string, transferable string) {
+	tick = strings.ToLower(tick)
+	ticker, ok := s.Tickers[tick]
+	balance, hasBalance := s.Balances[address][tick]
+	if !ok || !hasBalance {
+		return "0", "0"
+	}
+	return formatAmount(balance.Available, ticker.Decimals), formatAmount(balance.Transferable, ticker.Decimals)
+}
+
+// TransferableInscriptions returns the unsent transfer inscriptions of tick held by address.
+func (s *Store) TransferableInscriptions(address string, tick string) []*Transfer {
+	tick = strings.ToLower(tick)
+	transfers := make([]*Transfer, 0)
+	for _, transfer := range s.Transfers {
+		if transfer.Tick == tick && transfer.From == address {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers
+}
+
+// Ticker returns the deployed ticker, nil when it is not deployed.
+func (s *Store) Ticker(tick string) *Ticker {
+	return s.Tickers[strings.ToLower(tick)]
+}
+
+// Inscription returns the indexed inscription, nil when it is unknown.
+func (s *Store) Inscription(id string) *Inscription {
+	return s.Inscriptions[id]
+}
+
+// InscriptionsOn returns the inscriptions carried by the unspent outpoint.
+func (s *Store) InscriptionsOn(outpoint wire.OutPoint) []*Inscription {
+	output, ok := s.Outputs[outpoint.String()]
+	if !ok {
+		return nil
+	}
+	inscriptions := make([]*Inscription, 0, len(output.Inscriptions))
+	for _, id := range output.Inscriptions {
+		inscriptions = append(inscriptions, s.Inscriptions[id])
+	}
+	return inscriptions
+}
+
+// FormatAmount formats an amount of a ticker with decimals as a decimal string.
+func (s *Store) FormatAmount(tick string, amount *big.Int) string {
+	ticker := s.Ticker(tick)
+	if ticker == nil {
+		return amount.String()
+	}
+	return formatAmount(amount, ticker.Decimals)
+}
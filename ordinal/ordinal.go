@@ -0,0 +1,124 @@
+// Package ordinal names inscriptions and the sats they sit on.
+package ordinal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// InscriptionID is an inscription, the Index-th revealed by transaction Txid.
+type InscriptionID struct {
+	Txid  chainhash.Hash
+	Index uint32
+}
+
+// ParseInscriptionID parses a <txid>i<index> inscription id.
+func ParseInscriptionID(s string) (InscriptionID, error) {
+	txid, index, ok := strings.Cut(s, "i")
+	if !ok {
+		return InscriptionID{}, fmt.Errorf("invalid inscription id: %q", s)
+	}
+	hash, err := parseTxid(txid)
+	if err != nil {
+		return InscriptionID{}, fmt.Errorf("invalid inscription id: %q", s)
+	}
+	n, err := parseUint32(index)
+	if err != nil {
+		return InscriptionID{}, fmt.Errorf("invalid inscription id: %q", s)
+	}
+	return InscriptionID{Txid: hash, Index: n}, nil
+}
+
+func (id InscriptionID) String() string {
+	return fmt.Sprintf("%si%d", id.Txid, id.Index)
+}
+
+// Outpoint is output Vout of transaction Txid.
+type Outpoint struct {
+	Txid chainhash.Hash
+	Vout uint32
+}
+
+// ParseOutpoint parses a <txid>:<vout> outpoint.
+func ParseOutpoint(s string) (Outpoint, error) {
+	txid, vout, ok := strings.Cut(s, ":")
+	if !ok {
+		return Outpoint{}, fmt.Errorf("invalid outpoint: %q", s)
+	}
+	hash, err := parseTxid(txid)
+	if err != nil {
+		return Outpoint{}, fmt.Errorf("invalid outpoint: %q", s)
+	}
+	n, err := parseUint32(vout)
+	if err != nil {
+		return Outpoint{}, fmt.Errorf("invalid outpoint: %q", s)
+	}
+	return Outpoint{Txid: hash, Vout: n}, nil
+}
+
+// FromWire returns the outpoint of a wire outpoint.
+func FromWire(outpoint wire.OutPoint) Outpoint {
+	return Outpoint{Txid: outpoint.Hash, Vout: outpoint.Index}
+}
+
+// Wire returns the outpoint as a transaction input spends it.
+func (o Outpoint) Wire() *wire.OutPoint {
+	return wire.NewOutPoint(&o.Txid, o.Vout)
+}
+
+func (o Outpoint) String() string {
+	return fmt.Sprintf("%s:%d", o.Txid, o.Vout)
+}
+
+// Satpoint is a sat, Offset sats into the value of Outpoint.
+type Satpoint struct {
+	Outpoint Outpoint
+	Offset   uint64
+}
+
+// ParseSatpoint parses a <txid>:<vout>:<offset> satpoint.
+func ParseSatpoint(s string) (Satpoint, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Satpoint{}, fmt.Errorf("invalid satpoint: %q", s)
+	}
+	outpoint, err := ParseOutpoint(s[:i])
+	if err != nil {
+		return Satpoint{}, fmt.Errorf("invalid satpoint: %q", s)
+	}
+	offset, err := strconv.ParseUint(s[i+1:], 10, 64)
+	if err != nil {
+		return Satpoint{}, fmt.Errorf("invalid satpoint: %q", s)
+	}
+	return Satpoint{Outpoint: outpoint, Offset: offset}, nil
+}
+
+func (s Satpoint) String() string {
+	return fmt.Sprintf("%v:%d", s.Outpoint, s.Offset)
+}
+
+// parseTxid parses a txid of exactly 64 hex digits, where chainhash would
+// also take shorter ones.
+func parseTxid(s string) (chainhash.Hash, error) {
+	if len(s) != chainhash.MaxHashStringSize {
+		return chainhash.Hash{}, fmt.Errorf("txid of %d characters", len(s))
+	}
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	return *hash, nil
+}
+
+// parseUint32 parses a decimal with no sign or leading zeros.
+func parseUint32(s string) (uint32, error) {
+	if len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("leading zero in %q", s)
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return uint32(n), err
+}
@@ -0,0 +1,59 @@
+package ordinal
+
+import "testing"
+
+const txid = "3bd72a7ef68776c0d8a9c57bdd5be7ba5b3d2a5e7e6e52dc0d6e8b4b1a1c6e3f"
+
+func Test_InscriptionID(t *testing.T) {
+	for _, s := range []string{txid + "i0", txid + "i10", txid + "i4294967295"} {
+		id, err := ParseInscriptionID(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id.String() != s || id.Txid.String() != txid {
+			t.Errorf("ParseInscriptionID(%q) = %v", s, id)
+		}
+	}
+	id, _ := ParseInscriptionID(txid + "i12")
+	if id.Index != 12 {
+		t.Errorf("index %d, expected 12", id.Index)
+	}
+	for _, s := range []string{"", txid, txid + "i", txid + "i-1", txid + "i01", txid + "i4294967296", txid[:63] + "i0", txid + "0i0", "g" + txid[1:] + "i0"} {
+		if _, err := ParseInscriptionID(s); err == nil {
+			t.Errorf("ParseInscriptionID(%q) should fail", s)
+		}
+	}
+}
+
+func Test_Outpoint(t *testing.T) {
+	outpoint, err := ParseOutpoint(txid + ":2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outpoint.Vout != 2 || outpoint.String() != txid+":2" {
+		t.Fatalf("parsed %v", outpoint)
+	}
+	if FromWire(*outpoint.Wire()) != outpoint || outpoint.Wire().String() != outpoint.String() {
+		t.Fatal("wire outpoint round trip")
+	}
+	for _, s := range []string{"", txid, txid + ":", txid + ":x", "3bd7:2", txid + ":2:0"} {
+		if _, err := ParseOutpoint(s); err == nil {
+			t.Errorf("ParseOutpoint(%q) should fail", s)
+		}
+	}
+}
+
+func Test_Satpoint(t *testing.T) {
+	satpoint, err := ParseSatpoint(txid + ":2:330")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if satpoint.Outpoint.Vout != 2 || satpoint.Offset != 330 || satpoint.String() != txid+":2:330" {
+		t.Fatalf("parsed %v", satpoint)
+	}
+	for _, s := range []string{"", txid + ":2", "3bd7:2:330", txid + ":2:-1", txid + ":2:330:1"} {
+		if _, err := ParseSatpoint(s); err == nil {
+			t.Errorf("ParseSatpoint(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Package txsize estimates the weight of transactions before they are signed.
+package txsize
+
+import (
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// MaxECDSASigLen is the longest DER signature with a low s and its sighash byte.
+	MaxECDSASigLen = 72
+	// SchnorrSigLen is a schnorr signature with the default sighash.
+	SchnorrSigLen = 64
+	// CompressedPubKeyLen is the length of a compressed public key.
+	CompressedPubKeyLen = 33
+	// ControlBlockBaseLen is the control block of a script that is the only tap leaf.
+	ControlBlockBaseLen = 33
+	// ControlBlockNodeLen is what each level of the tap tree adds to a control block.
+	ControlBlockNodeLen = 32
+
+	// outpoint, sequence
+	inputBaseSize = 32 + 4 + 4
+	// version, locktime
+	txBaseSize = 4 + 4
+	// segwit marker and flag
+	segwitHeaderWeight = 2
+)
+
+// Estimator accumulates the weight of a transaction from the kinds of its inputs
+// and its outputs, sized as if every signature had its maximal length.
+type Estimator struct {
+	inputs        int
+	outputs       int
+	inputsWeight  int64
+	outputsWeight int64
+	// witnessInputs counts the inputs with a witness, legacy inputs of a segwit
+	// transaction still carry an empty witness.
+	witnessInputs int
+}
+
+// VarIntSize is the serialized length of a compact size integer.
+func VarIntSize(n uint64) int64 {
+	return int64(wire.VarIntSerializeSize(n))
+}
+
+// pushSize is the length of the smallest script push of n bytes.
+func pushSize(n int) int64 {
+	switch {
+	case n < 76:
+		return int64(1 + n)
+	case n <= 0xff:
+		return int64(2 + n)
+	case n <= 0xffff:
+		return int64(3 + n)
+	default:
+		return int64(5 + n)
+	}
+}
+
+// witnessSize is the serialized length of a witness with items of the given lengths.
+func witnessSize(items ...int) int64 {
+	size := VarIntSize(uint64(len(items)))
+	for _, item := range items {
+		size += VarIntSize(uint64(item)) + int64(item)
+	}
+	return size
+}
+
+func (e *Estimator) addInput(scriptSigLen int64, witness int64) {
+	e.inputs++
+	e.inputsWeight += (inputBaseSize + VarIntSize(uint64(scriptSigLen)) + scriptSigLen) * 4
+	if witness > 0 {
+		e.witnessInputs++
+		e.inputsWeight += witness
+	}
+}
+
+// AddP2WPKHInput adds a P2WPKH input.
+func (e *Estimator) AddP2WPKHInput() {
+	e.addInput(0, witnessSize(MaxECDSASigLen, CompressedPubKeyLen))
+}
+
+// multisigStack is the lengths of the stack spending an m-of-n CHECKMULTISIG,
+// the dummy element first.
+func multisigStack(m int) []int {
+	items := make([]int, 0, m+1)
+	items = append(items, 0)
+	for i := 0; i < m; i++ {
+		items = append(items, MaxECDSASigLen)
+	}
+	return items
+}
+
+// AddP2SHMultisigInput adds a legacy P2SH input spending an m-of-n multisig redeem script.
+func (e *Estimator) AddP2SHMultisigInput(m int, redeemScriptLen int) {
+	scriptSig := int64(0)
+	for _, item := range multisigStack(m) {
+		scriptSig += pushSize(item)
+	}
+	scriptSig += pushSize(redeemScriptLen)
+	e.addInput(scriptSig, 0)
+}
+
+// AddP2WSHMultisigInput adds a P2WSH input spending an m-of-n multisig witness script.
+func (e *Estimator) AddP2WSHMultisigInput(m int, witnessScriptLen int) {
+	e.addInput(0, witnessSize(append(multisigStack(m), witnessScriptLen)...))
+}
+
+// AddP2SHP2WSHMultisigInput adds a P2WSH input nested in P2SH spending an m-of-n multisig witness script.
+func (e *Estimator) AddP2SHP2WSHMultisigInput(m int, witnessScriptLen int) {
+	// the scriptSig pushes the version 0 witness program of the script hash
+	e.addInput(pushSize(2+32), witnessSize(append(multisigStack(m), witnessScriptLen)...))
+}
+
+// AddTaprootKeyInput adds a P2TR key path input.
+func (e *Estimator) AddTaprootKeyInput() {
+	e.addInput(0, witnessSize(SchnorrSigLen))
+}
+
+// AddTaprootScriptInput adds a P2TR script path input revealing a script of
+// scriptLen at depth in the tap tree, satisfied by stack items of the given lengths.
+func (e *Estimator) AddTaprootScriptInput(stack []int, scriptLen int, depth int) {
+	items := append(append([]int{}, stack...), scriptLen, ControlBlockBaseLen+depth*ControlBlockNodeLen)
+	e.addInput(0, witnessSize(items...))
+}
+
+// AddOutput adds an output paying pkScript.
+func (e *Estimator) AddOutput(pkScript []byte) {
+	e.outputs++
+	e.outputsWeight += int64(wire.NewTxOut(0, pkScript).SerializeSize()) * 4
+}
+
+// AddOutputs adds the outputs of tx.
+func (e *Estimator) AddOutputs(tx *wire.MsgTx) {
+	for _, txOut := range tx.TxOut {
+		e.AddOutput(txOut.PkScript)
+	}
+}
+
+// Weight returns the estimated weight of the transaction.
+func (e *Estimator) Weight() int64 {
+	weight := (txBaseSize + VarIntSize(uint64(e.inputs)) + VarIntSize(uint64(e.outputs))) * 4
+	weight += e.inputsWeight + e.outputsWeight
+	if e.witnessInputs > 0 {
+		weight += segwitHeaderWeight + int64(e.inputs-e.witnessInputs)
+	}
+	return weight
+}
+
+// VSize returns the estimated virtual size of the transaction.
+func (e *Estimator) VSize() int64 {
+	return (e.Weight() + 3) / 4
+}
+
+// Fee returns the fee paying feerate satoshi per virtual byte.
+func (e *Estimator) Fee(feerate int64) int64 {
+	return e.VSize() * feerate
+}
+
+// Clone returns a copy of e that can grow on its own.
+func (e *Estimator) Clone() *Estimator {
+	clone := *e
+	return &clone
+}
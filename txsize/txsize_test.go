@@ -0,0 +1,143 @@
+package txsize
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// filled returns a witness item of length n.
+func filled(n int) []byte {
+	return bytes.Repeat([]byte{1}, n)
+}
+
+func newInput() *wire.TxIn {
+	return wire.NewTxIn(&wire.OutPoint{}, nil, nil)
+}
+
+// Test_Weight builds transactions whose signatures have the maximal length the
+// estimator assumes and checks it sizes them exactly.
+func Test_Weight(t *testing.T) {
+	p2wpkh := filled(22)
+	p2tr := filled(34)
+	redeemScript := filled(105)
+	tapscript := filled(120)
+
+	tests := []struct {
+		name  string
+		tx    func() *wire.MsgTx
+		build func(e *Estimator)
+	}{
+		{
+			name: "p2wpkh",
+			tx: func() *wire.MsgTx {
+				tx := wire.NewMsgTx(2)
+				in := newInput()
+				in.Witness = wire.TxWitness{filled(MaxECDSASigLen), filled(CompressedPubKeyLen)}
+				tx.AddTxIn(in)
+				tx.AddTxOut(wire.NewTxOut(1, p2wpkh))
+				tx.AddTxOut(wire.NewTxOut(1, p2tr))
+				return tx
+			},
+			build: func(e *Estimator) {
+				e.AddP2WPKHInput()
+				e.AddOutput(p2wpkh)
+				e.AddOutput(p2tr)
+			},
+		},
+		{
+			name: "p2sh multisig with p2wpkh fee input",
+			tx: func() *wire.MsgTx {
+				tx := wire.NewMsgTx(1)
+				in := newInput()
+				var scriptSig []byte
+				scriptSig = append(scriptSig, 0)
+				for i := 0; i < 2; i++ {
+					scriptSig = append(scriptSig, MaxECDSASigLen)
+					scriptSig = append(scriptSig, filled(MaxECDSASigLen)...)
+				}
+				scriptSig = append(scriptSig, 0x4c, byte(len(redeemScript)))
+				scriptSig = append(scriptSig, redeemScript...)
+				in.SignatureScript = scriptSig
+				tx.AddTxIn(in)
+				fee := newInput()
+				fee.Witness = wire.TxWitness{filled(MaxECDSASigLen), filled(CompressedPubKeyLen)}
+				tx.AddTxIn(fee)
+				tx.AddTxOut(wire.NewTxOut(1, p2wpkh))
+				return tx
+			},
+			build: func(e *Estimator) {
+				e.AddP2SHMultisigInput(2, len(redeemScript))
+				e.AddP2WPKHInput()
+				e.AddOutput(p2wpkh)
+			},
+		},
+		{
+			name: "p2wsh multisig",
+			tx: func() *wire.MsgTx {
+				tx := wire.NewMsgTx(2)
+				in := newInput()
+				in.Witness = wire.TxWitness{nil, filled(MaxECDSASigLen), filled(MaxECDSASigLen), redeemScript}
+				tx.AddTxIn(in)
+				tx.AddTxOut(wire.NewTxOut(1, p2wpkh))
+				return tx
+			},
+			build: func(e *Estimator) {
+				e.AddP2WSHMultisigInput(2, len(redeemScript))
+				e.AddOutput(p2wpkh)
+			},
+		},
+		{
+			name: "p2sh-p2wsh multisig with p2wpkh fee input",
+			tx: func() *wire.MsgTx {
+				tx := wire.NewMsgTx(2)
+				in := newInput()
+				in.SignatureScript = append([]byte{34, 0, 32}, filled(32)...)
+				in.Witness = wire.TxWitness{nil, filled(MaxECDSASigLen), filled(MaxECDSASigLen), redeemScript}
+				tx.AddTxIn(in)
+				fee := newInput()
+				fee.Witness = wire.TxWitness{filled(MaxECDSASigLen), filled(CompressedPubKeyLen)}
+				tx.AddTxIn(fee)
+				tx.AddTxOut(wire.NewTxOut(1, p2wpkh))
+				return tx
+			},
+			build: func(e *Estimator) {
+				e.AddP2SHP2WSHMultisigInput(2, len(redeemScript))
+				e.AddP2WPKHInput()
+				e.AddOutput(p2wpkh)
+			},
+		},
+		{
+			name: "taproot script and key path",
+			tx: func() *wire.MsgTx {
+				tx := wire.NewMsgTx(2)
+				in := newInput()
+				in.Witness = wire.TxWitness{filled(SchnorrSigLen), tapscript, filled(ControlBlockBaseLen + ControlBlockNodeLen)}
+				tx.AddTxIn(in)
+				key := newInput()
+				key.Witness = wire.TxWitness{filled(SchnorrSigLen)}
+				tx.AddTxIn(key)
+				tx.AddTxOut(wire.NewTxOut(1, p2tr))
+				return tx
+			},
+			build: func(e *Estimator) {
+				e.AddTaprootScriptInput([]int{SchnorrSigLen}, len(tapscript), 1)
+				e.AddTaprootKeyInput()
+				e.AddOutput(p2tr)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := &Estimator{}
+			test.build(e)
+			weight := blockchain.GetTransactionWeight(btcutil.NewTx(test.tx()))
+			if e.Weight() != weight {
+				t.Fatalf("estimated weight %d, actual %d", e.Weight(), weight)
+			}
+		})
+	}
+}